@@ -18,6 +18,10 @@
 package kinetic
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	kproto "github.com/Kinetic/kinetic-go/proto"
 )
 
@@ -25,27 +29,54 @@ import (
 // For all API functions, it will only return after response from kinetic device handled.
 // If no data required from kinetic device, API function will return Status and error.
 // If any data required from kinetic device, the data will be one of the return values.
+// Requests exceeding kinetic device limits from handshake LimitsLog are rejected with *LimitError,
+// without sending to device.
+// Requests failed with transient status are retried following RetryPolicy, from ClientOptions.Retry
+// or SetRetryPolicy.
+// BlockConnection is safe for concurrent use by multiple goroutines. Requests from all goroutines are
+// pipelined over the single network connection, each call waits only for response of its own request.
 type BlockConnection struct {
-	nbc *NonBlockConnection
+	nbc   *NonBlockConnection
+	mu    sync.Mutex
+	retry *RetryPolicy
 }
 
 // NewBlockConnection is helper function to establish block connection to device.
 func NewBlockConnection(op ClientOptions) (*BlockConnection, error) {
 	nbc, err := NewNonBlockConnection(op)
 	if err != nil {
-		klog.Error("Can't establish nonblocking connection")
+		newContextLogger(op.Logger, Fields{FieldHost: op.Host}).error("Can't establish nonblocking connection", Fields{FieldError: err.Error()})
 		return nil, err
 	}
 
-	return &BlockConnection{nbc: nbc}, err
+	return &BlockConnection{nbc: nbc, retry: op.Retry}, err
 }
 
-// NoOp does nothing but wait for drive to return response.
-// On success, Status.Code will be OK
-func (conn *BlockConnection) NoOp() (Status, error) {
-	callback := &GenericCallback{}
+// log returns the logger of underlying connection.
+func (conn *BlockConnection) log() *contextLogger {
+	if conn.nbc == nil {
+		return nil
+	}
+	return conn.nbc.service.log()
+}
+
+// SetRetryPolicy changes the retry policy for following requests, nil to disable retry.
+func (conn *BlockConnection) SetRetryPolicy(policy *RetryPolicy) {
+	conn.mu.Lock()
+	conn.retry = policy
+	conn.mu.Unlock()
+}
+
+func (conn *BlockConnection) retryPolicy() *RetryPolicy {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.retry
+}
+
+// roundTrip sends request by submit with new ResponseHandler for callback, and waits for response message.
+func (conn *BlockConnection) roundTrip(callback Callback, submit func(h *ResponseHandler) error) (Status, error) {
 	h := NewResponseHandler(callback)
-	err := conn.nbc.NoOp(h)
+	err := submit(h)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -55,126 +86,206 @@ func (conn *BlockConnection) NoOp() (Status, error) {
 	return callback.Status(), err
 }
 
-func (conn *BlockConnection) get(key []byte, getCmd kproto.Command_MessageType) (*Record, Status, error) {
-	callback := &GetCallback{}
-	h := NewResponseHandler(callback)
+// hasPrecondition returns true if write of entry carries version precondition. Without Force, nil Version
+// is a precondition too, the object must not exist.
+func hasPrecondition(entry *Record) bool {
+	return !entry.Force
+}
 
-	var err error
-	switch getCmd {
-	case kproto.Command_GET:
-		err = conn.nbc.Get(key, h)
-	case kproto.Command_GETPREVIOUS:
-		err = conn.nbc.GetPrevious(key, h)
-	case kproto.Command_GETNEXT:
-		err = conn.nbc.GetNext(key, h)
+// withRetry calls attempt until it succeeds, or fails with status not retryable by RetryPolicy, or
+// attempts exhausted. precondition is true if write request carries version precondition.
+func (conn *BlockConnection) withRetry(t MessageType, precondition bool, opts []RequestOption, attempt func() (Status, error)) (Status, error) {
+	status, err := attempt()
+
+	policy := conn.retryPolicy()
+	if policy == nil {
+		return status, err
 	}
-	if err != nil {
-		return nil, callback.Status(), err
+
+	var done <-chan struct{}
+	if ctx := newRequestOptions(opts).ctx; ctx != nil {
+		done = ctx.Done()
+	}
+	for k := 1; k < policy.MaxAttempts; k++ {
+		code := status.Code
+		if err != nil {
+			c, ok := statusCode(err)
+			if !ok {
+				break
+			}
+			code = c
+		}
+		if code == OK || !policy.retryable(t, precondition, code) {
+			break
+		}
+
+		conn.log().debug("Retry request", Fields{FieldMessageType: t.String(), "attempt": k + 1, FieldStatus: code.String()})
+		timer := time.NewTimer(policy.delay(k))
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return status, err
+		}
+		status, err = attempt()
 	}
 
-	err = conn.nbc.Listen(h)
+	return status, err
+}
 
-	return &callback.Entry, callback.Status(), err
+// NoOp does nothing but wait for drive to return response.
+// On success, Status.Code will be OK
+func (conn *BlockConnection) NoOp(opts ...RequestOption) (Status, error) {
+	return conn.withRetry(MessageNoop, true, opts, func() (Status, error) {
+		return conn.roundTrip(&GenericCallback{}, func(h *ResponseHandler) error {
+			return conn.nbc.NoOp(h, opts...)
+		})
+	})
+}
+
+func (conn *BlockConnection) get(key []byte, getCmd kproto.Command_MessageType, metaOnly bool, opts []RequestOption) (*Record, Status, error) {
+	var callback *GetCallback
+	status, err := conn.withRetry(convertMessageTypeFromProto(getCmd), true, opts, func() (Status, error) {
+		callback = &GetCallback{}
+		return conn.roundTrip(callback, func(h *ResponseHandler) error {
+			return conn.nbc.get(key, getCmd, metaOnly, h, opts)
+		})
+	})
+
+	if metaOnly {
+		callback.Entry.MetaOnly = true
+		callback.Entry.Value = nil
+	}
+
+	return &callback.Entry, status, err
 }
 
 // Get gets the object from kinetic drive with key.
 // On success, object Record will return and Status.Code = OK
-func (conn *BlockConnection) Get(key []byte) (*Record, Status, error) {
-	return conn.get(key, kproto.Command_GET)
+func (conn *BlockConnection) Get(key []byte, opts ...RequestOption) (*Record, Status, error) {
+	return conn.get(key, kproto.Command_GET, false, opts)
 }
 
 // GetNext gets the next object with key after the passed in key.
 // On success, object Record will return and Status.Code = OK
-func (conn *BlockConnection) GetNext(key []byte) (*Record, Status, error) {
-	return conn.get(key, kproto.Command_GETNEXT)
+func (conn *BlockConnection) GetNext(key []byte, opts ...RequestOption) (*Record, Status, error) {
+	return conn.get(key, kproto.Command_GETNEXT, false, opts)
 }
 
 // GetPrevious gets the previous object with key before the passed in key.
 // On success, object Record will return and Status.Code = OK
-func (conn *BlockConnection) GetPrevious(key []byte) (*Record, Status, error) {
-	return conn.get(key, kproto.Command_GETPREVIOUS)
+func (conn *BlockConnection) GetPrevious(key []byte, opts ...RequestOption) (*Record, Status, error) {
+	return conn.get(key, kproto.Command_GETPREVIOUS, false, opts)
+}
+
+// GetMeta gets the object metadata from kinetic drive with key, object value is not transferred.
+// On success, object Record with MetaOnly = true and no Value will return and Status.Code = OK
+func (conn *BlockConnection) GetMeta(key []byte, opts ...RequestOption) (*Record, Status, error) {
+	return conn.get(key, kproto.Command_GET, true, opts)
+}
+
+// GetNextMeta gets the metadata of next object with key after the passed in key.
+// On success, object Record with MetaOnly = true and no Value will return and Status.Code = OK
+func (conn *BlockConnection) GetNextMeta(key []byte, opts ...RequestOption) (*Record, Status, error) {
+	return conn.get(key, kproto.Command_GETNEXT, true, opts)
+}
+
+// GetPreviousMeta gets the metadata of previous object with key before the passed in key.
+// On success, object Record with MetaOnly = true and no Value will return and Status.Code = OK
+func (conn *BlockConnection) GetPreviousMeta(key []byte, opts ...RequestOption) (*Record, Status, error) {
+	return conn.get(key, kproto.Command_GETPREVIOUS, true, opts)
 }
 
 // GetKeyRange gets list of objects' keys, which meet the criteria defined by KeyRange.
 // On success, list of objects's keys returned, and Status.Code = OK
-func (conn *BlockConnection) GetKeyRange(r *KeyRange) ([][]byte, Status, error) {
-	callback := &GetKeyRangeCallback{}
-	h := NewResponseHandler(callback)
-	err := conn.nbc.GetKeyRange(r, h)
-	if err != nil {
-		return nil, callback.Status(), err
-	}
-
-	err = conn.nbc.Listen(h)
+func (conn *BlockConnection) GetKeyRange(r *KeyRange, opts ...RequestOption) ([][]byte, Status, error) {
+	var callback *GetKeyRangeCallback
+	status, err := conn.withRetry(MessageGetKeyRange, true, opts, func() (Status, error) {
+		callback = &GetKeyRangeCallback{}
+		return conn.roundTrip(callback, func(h *ResponseHandler) error {
+			return conn.nbc.GetKeyRange(r, h, opts...)
+		})
+	})
 
-	return callback.Keys, callback.Status(), err
+	return callback.Keys, status, err
 }
 
 // GetVersion gets object DB version information.
 // On success, version information will return and Status.Code = OK
-func (conn *BlockConnection) GetVersion(key []byte) ([]byte, Status, error) {
-	callback := &GetVersionCallback{}
-	h := NewResponseHandler(callback)
-	err := conn.nbc.GetVersion(key, h)
-	if err != nil {
-		return nil, callback.Status(), err
-	}
+func (conn *BlockConnection) GetVersion(key []byte, opts ...RequestOption) ([]byte, Status, error) {
+	var callback *GetVersionCallback
+	status, err := conn.withRetry(MessageGetVersion, true, opts, func() (Status, error) {
+		callback = &GetVersionCallback{}
+		return conn.roundTrip(callback, func(h *ResponseHandler) error {
+			return conn.nbc.GetVersion(key, h, opts...)
+		})
+	})
 
-	err = conn.nbc.Listen(h)
-
-	return callback.Version, callback.Status(), err
+	return callback.Version, status, err
 }
 
 // Flush requests kinetic device to write all cached data to persistent media.
 // On success, Status.Code = OK
-func (conn *BlockConnection) Flush() (Status, error) {
-	callback := &GenericCallback{}
-	h := NewResponseHandler(callback)
-	err := conn.nbc.Flush(h)
-	if err != nil {
-		return callback.Status(), err
-	}
-
-	err = conn.nbc.Listen(h)
+func (conn *BlockConnection) Flush(opts ...RequestOption) (Status, error) {
+	return conn.withRetry(MessageFlushAllData, true, opts, func() (Status, error) {
+		return conn.roundTrip(&GenericCallback{}, func(h *ResponseHandler) error {
+			return conn.nbc.Flush(h, opts...)
+		})
+	})
+}
 
-	return callback.Status(), err
+// versionConflict completes the conflict reported by kinetic device with the expected version.
+func (conn *BlockConnection) versionConflict(entry *Record, conflict *VersionConflictError) *VersionConflictError {
+	conflict.Key = entry.Key
+	conflict.ExpectedVersion = entry.Version
+	return conflict
 }
 
 // Delete deletes object from kinetic device.
 // On success, Status.Code = OK
-func (conn *BlockConnection) Delete(entry *Record) (Status, error) {
-	callback := &GenericCallback{}
-	h := NewResponseHandler(callback)
-	err := conn.nbc.Delete(entry, h)
-	if err != nil {
-		return callback.Status(), err
+// If entry.Version doesn't match object version on kinetic device, Status.Code = RemoteVersionMismatch
+// and error is *VersionConflictError.
+func (conn *BlockConnection) Delete(entry *Record, opts ...RequestOption) (Status, error) {
+	var callback *WriteCallback
+	status, err := conn.withRetry(MessageDelete, hasPrecondition(entry), opts, func() (Status, error) {
+		callback = &WriteCallback{}
+		return conn.roundTrip(callback, func(h *ResponseHandler) error {
+			return conn.nbc.Delete(entry, h, opts...)
+		})
+	})
+
+	if err == nil && callback.Conflict != nil {
+		return status, conn.versionConflict(entry, callback.Conflict)
 	}
 
-	err = conn.nbc.Listen(h)
-
-	return callback.Status(), err
+	return status, err
 }
 
 // Put store object to kinetic device.
 // On success, Status.Code = OK
-func (conn *BlockConnection) Put(entry *Record) (Status, error) {
-	callback := &GenericCallback{}
-	h := NewResponseHandler(callback)
-	err := conn.nbc.Put(entry, h)
-	if err != nil {
-		return callback.Status(), err
+// If entry.Version doesn't match object version on kinetic device, Status.Code = RemoteVersionMismatch
+// and error is *VersionConflictError.
+func (conn *BlockConnection) Put(entry *Record, opts ...RequestOption) (Status, error) {
+	var callback *WriteCallback
+	status, err := conn.withRetry(MessagePut, hasPrecondition(entry), opts, func() (Status, error) {
+		callback = &WriteCallback{}
+		return conn.roundTrip(callback, func(h *ResponseHandler) error {
+			return conn.nbc.Put(entry, h, opts...)
+		})
+	})
+
+	if err == nil && callback.Conflict != nil {
+		return status, conn.versionConflict(entry, callback.Conflict)
 	}
 
-	err = conn.nbc.Listen(h)
-
-	return callback.Status(), err
+	return status, err
 }
 
 // P2PPush performs peer to peer push operation
-func (conn *BlockConnection) P2PPush(request *P2PPushRequest) (*P2PPushStatus, Status, error) {
+func (conn *BlockConnection) P2PPush(request *P2PPushRequest, opts ...RequestOption) (*P2PPushStatus, Status, error) {
 	callback := &P2PPushCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.P2PPush(request, h)
+	err := conn.nbc.P2PPush(request, h, opts...)
 	if err != nil {
 		return nil, callback.Status(), err
 	}
@@ -186,10 +297,10 @@ func (conn *BlockConnection) P2PPush(request *P2PPushRequest) (*P2PPushStatus, S
 
 // BatchStart starts new batch operation, all following batch PUT / DELETE share same batch ID until
 // BatchEnd or BatchAbort is called.
-func (conn *BlockConnection) BatchStart() (Status, error) {
+func (conn *BlockConnection) BatchStart(opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.BatchStart(h)
+	err := conn.nbc.BatchStart(h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -201,22 +312,22 @@ func (conn *BlockConnection) BatchStart() (Status, error) {
 
 // BatchPut puts objects to kinetic drive, as a batch job. Batch PUT / DELETE won't expect acknowledgement
 // from kinetic device. Status for batch PUT / DELETE will only available in response message for BatchEnd.
-func (conn *BlockConnection) BatchPut(entry *Record) error {
-	return conn.nbc.BatchPut(entry)
+func (conn *BlockConnection) BatchPut(entry *Record, opts ...RequestOption) error {
+	return conn.nbc.BatchPut(entry, opts...)
 }
 
 // BatchDelete delete object from kinetic drive, as a batch job. Batch PUT / DELETE won't expect acknowledgement
 // from kinetic device. Status for batch PUT / DELETE will only available in response message for BatchEnd.
-func (conn *BlockConnection) BatchDelete(entry *Record) error {
-	return conn.nbc.BatchDelete(entry)
+func (conn *BlockConnection) BatchDelete(entry *Record, opts ...RequestOption) error {
+	return conn.nbc.BatchDelete(entry, opts...)
 }
 
 // BatchEnd commits all batch jobs. Response from kinetic device will indicate succeeded jobs sequence number, or
 // the first failed job sequence number if there is a failure.
-func (conn *BlockConnection) BatchEnd() (*BatchStatus, Status, error) {
+func (conn *BlockConnection) BatchEnd(opts ...RequestOption) (*BatchStatus, Status, error) {
 	callback := &BatchEndCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.BatchEnd(h)
+	err := conn.nbc.BatchEnd(h, opts...)
 	if err != nil {
 		return nil, callback.Status(), err
 	}
@@ -227,10 +338,10 @@ func (conn *BlockConnection) BatchEnd() (*BatchStatus, Status, error) {
 }
 
 // BatchAbort aborts jobs in current batch operation.
-func (conn *BlockConnection) BatchAbort() (Status, error) {
+func (conn *BlockConnection) BatchAbort(opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.BatchAbort(h)
+	err := conn.nbc.BatchAbort(h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -242,33 +353,32 @@ func (conn *BlockConnection) BatchAbort() (Status, error) {
 
 // GetLog gets kinetic device Log information. Can request single LogType or multiple LogType.
 // On success, device Log information will return, and Status.Code = OK
-func (conn *BlockConnection) GetLog(logs []LogType) (*Log, Status, error) {
-	callback := &GetLogCallback{}
-	h := NewResponseHandler(callback)
-	err := conn.nbc.GetLog(logs, h)
-	if err != nil {
-		return nil, callback.Status(), err
-	}
-
-	err = conn.nbc.Listen(h)
+func (conn *BlockConnection) GetLog(logs []LogType, opts ...RequestOption) (*Log, Status, error) {
+	var callback *GetLogCallback
+	status, err := conn.withRetry(MessageGetLog, true, opts, func() (Status, error) {
+		callback = &GetLogCallback{}
+		return conn.roundTrip(callback, func(h *ResponseHandler) error {
+			return conn.nbc.GetLog(logs, h, opts...)
+		})
+	})
 
-	return &callback.Logs, callback.Status(), err
+	return &callback.Logs, status, err
 }
 
-func (conn *BlockConnection) pinop(pin []byte, op kproto.Command_PinOperation_PinOpType) (Status, error) {
+func (conn *BlockConnection) pinop(pin []byte, op kproto.Command_PinOperation_PinOpType, opts []RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
 
 	var err error
 	switch op {
 	case kproto.Command_PinOperation_SECURE_ERASE_PINOP:
-		err = conn.nbc.SecureErase(pin, h)
+		err = conn.nbc.SecureErase(pin, h, opts...)
 	case kproto.Command_PinOperation_ERASE_PINOP:
-		err = conn.nbc.InstantErase(pin, h)
+		err = conn.nbc.InstantErase(pin, h, opts...)
 	case kproto.Command_PinOperation_LOCK_PINOP:
-		err = conn.nbc.LockDevice(pin, h)
+		err = conn.nbc.LockDevice(pin, h, opts...)
 	case kproto.Command_PinOperation_UNLOCK_PINOP:
-		err = conn.nbc.UnlockDevice(pin, h)
+		err = conn.nbc.UnlockDevice(pin, h, opts...)
 	}
 	if err != nil {
 		return callback.Status(), err
@@ -282,39 +392,39 @@ func (conn *BlockConnection) pinop(pin []byte, op kproto.Command_PinOperation_Pi
 // SecureErase request kinetic device to perform secure erase.
 // SSL connection is requested to perform this operation, and the erase pin is needed.
 // On success, Status.Code = OK
-func (conn *BlockConnection) SecureErase(pin []byte) (Status, error) {
-	return conn.pinop(pin, kproto.Command_PinOperation_SECURE_ERASE_PINOP)
+func (conn *BlockConnection) SecureErase(pin []byte, opts ...RequestOption) (Status, error) {
+	return conn.pinop(pin, kproto.Command_PinOperation_SECURE_ERASE_PINOP, opts)
 }
 
 // InstantErase request kinetic device to perform instant erase.
 // SSL connection is requested to perform this operation, and the erase pin is needed.
 // On success, Status.Code = OK
-func (conn *BlockConnection) InstantErase(pin []byte) (Status, error) {
-	return conn.pinop(pin, kproto.Command_PinOperation_ERASE_PINOP)
+func (conn *BlockConnection) InstantErase(pin []byte, opts ...RequestOption) (Status, error) {
+	return conn.pinop(pin, kproto.Command_PinOperation_ERASE_PINOP, opts)
 
 }
 
 // LockDevice locks the kinetic device.
 // SSL connection is requested to perform this operation, and the lock pin is needed.
 // On success, Status.Code = OK
-func (conn *BlockConnection) LockDevice(pin []byte) (Status, error) {
-	return conn.pinop(pin, kproto.Command_PinOperation_LOCK_PINOP)
+func (conn *BlockConnection) LockDevice(pin []byte, opts ...RequestOption) (Status, error) {
+	return conn.pinop(pin, kproto.Command_PinOperation_LOCK_PINOP, opts)
 }
 
 // UnlockDevice unlocks the kinetic device.
 // SSL connection is requested to perform this operation, and the lock pin is needed.
 // On success, Status.Code = OK
-func (conn *BlockConnection) UnlockDevice(pin []byte) (Status, error) {
-	return conn.pinop(pin, kproto.Command_PinOperation_UNLOCK_PINOP)
+func (conn *BlockConnection) UnlockDevice(pin []byte, opts ...RequestOption) (Status, error) {
+	return conn.pinop(pin, kproto.Command_PinOperation_UNLOCK_PINOP, opts)
 }
 
 // UpdateFirmware requests to update kientic device firmware.
 // Status.OK will return if firmware data received by kinetic device.
 // Then drive will reboot and perform the firmware update process.
-func (conn *BlockConnection) UpdateFirmware(code []byte) (Status, error) {
+func (conn *BlockConnection) UpdateFirmware(code []byte, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.UpdateFirmware(code, h)
+	err := conn.nbc.UpdateFirmware(code, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -326,10 +436,10 @@ func (conn *BlockConnection) UpdateFirmware(code []byte) (Status, error) {
 
 // SetClusterVersion sets the cluster version on kinetic drive.
 // On success, Status.Code = OK.
-func (conn *BlockConnection) SetClusterVersion(version int64) (Status, error) {
+func (conn *BlockConnection) SetClusterVersion(version int64, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.SetClusterVersion(version, h)
+	err := conn.nbc.SetClusterVersion(version, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -344,13 +454,38 @@ func (conn *BlockConnection) SetClientClusterVersion(version int64) {
 	conn.nbc.SetClientClusterVersion(version)
 }
 
+// ClientClusterVersion returns the cluster version for all following message to kinetic device.
+// It's the cluster version from handshake, unless changed by SetClientClusterVersion.
+func (conn *BlockConnection) ClientClusterVersion() int64 {
+	return conn.nbc.ClientClusterVersion()
+}
+
+// DeviceLog returns kinetic device Configuration and Limits received in handshake when connection established.
+// No GetLog request is sent to kinetic device.
+func (conn *BlockConnection) DeviceLog() Log {
+	return conn.nbc.DeviceLog()
+}
+
+// ConnectionID returns the connection ID assigned by kinetic device in handshake.
+func (conn *BlockConnection) ConnectionID() int64 {
+	return conn.nbc.ConnectionID()
+}
+
+// SubscribeUnsolicitedStatus registers f to receive unsolicited status messages from kinetic device,
+// returns the function to unsubscribe. For terminal status (RemoteConnectionTerminated, RemoteHibernate,
+// RemoteShutdown), connection is closed and all outstanding requests fail with the status, unless
+// ClientOptions.Reconnect is set, then connection reconnects as on network failure.
+func (conn *BlockConnection) SubscribeUnsolicitedStatus(f UnsolicitedStatusFunc) (unsubscribe func()) {
+	return conn.nbc.SubscribeUnsolicitedStatus(f)
+}
+
 // SetLockPin changes kinetic device lock pin. Both current pin and new pin needed.
 // SSL connection is required to perform this operation.
 // On success, Status.Code = OK.
-func (conn *BlockConnection) SetLockPin(currentPin []byte, newPin []byte) (Status, error) {
+func (conn *BlockConnection) SetLockPin(currentPin []byte, newPin []byte, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.SetLockPin(currentPin, newPin, h)
+	err := conn.nbc.SetLockPin(currentPin, newPin, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -363,10 +498,10 @@ func (conn *BlockConnection) SetLockPin(currentPin []byte, newPin []byte) (Statu
 // SetErasePin changes kinetic device erase pin. Both current pin and new pin needed.
 // SSL connection is required to perform this operation.
 // On success, Status.Code = OK.
-func (conn *BlockConnection) SetErasePin(currentPin []byte, newPin []byte) (Status, error) {
+func (conn *BlockConnection) SetErasePin(currentPin []byte, newPin []byte, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.SetErasePin(currentPin, newPin, h)
+	err := conn.nbc.SetErasePin(currentPin, newPin, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -378,10 +513,10 @@ func (conn *BlockConnection) SetErasePin(currentPin []byte, newPin []byte) (Stat
 
 // SetACL sets Permission for particular user Identity.
 // On success, Status.Code = OK.
-func (conn *BlockConnection) SetACL(acls []ACL) (Status, error) {
+func (conn *BlockConnection) SetACL(acls []ACL, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.SetACL(acls, h)
+	err := conn.nbc.SetACL(acls, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -394,10 +529,10 @@ func (conn *BlockConnection) SetACL(acls []ACL) (Status, error) {
 // MediaScan is to check that the user data is readable, and
 // if the end to end integrity is known to the device, if the
 // end to end integrity field is correct.
-func (conn *BlockConnection) MediaScan(op *MediaOperation, pri Priority) (Status, error) {
+func (conn *BlockConnection) MediaScan(op *MediaOperation, pri Priority, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.MediaScan(op, pri, h)
+	err := conn.nbc.MediaScan(op, pri, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -410,10 +545,10 @@ func (conn *BlockConnection) MediaScan(op *MediaOperation, pri Priority) (Status
 // MediaOptimize performs optimizations of the media. Things like
 // defragmentation, compaction, garbage collection, compression
 // could be things accomplished using the media optimize command.
-func (conn *BlockConnection) MediaOptimize(op *MediaOperation, pri Priority) (Status, error) {
+func (conn *BlockConnection) MediaOptimize(op *MediaOperation, pri Priority, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.MediaOptimize(op, pri, h)
+	err := conn.nbc.MediaOptimize(op, pri, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -424,10 +559,10 @@ func (conn *BlockConnection) MediaOptimize(op *MediaOperation, pri Priority) (St
 }
 
 // SetPowerLevel sets device power level
-func (conn *BlockConnection) SetPowerLevel(p PowerLevel) (Status, error) {
+func (conn *BlockConnection) SetPowerLevel(p PowerLevel, opts ...RequestOption) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.SetPowerLevel(p, h)
+	err := conn.nbc.SetPowerLevel(p, h, opts...)
 	if err != nil {
 		return callback.Status(), err
 	}
@@ -437,7 +572,15 @@ func (conn *BlockConnection) SetPowerLevel(p PowerLevel) (Status, error) {
 	return callback.Status(), err
 }
 
-// Close the connection to kientic device
+// Close the connection to kientic device immediately. All outstanding requests fail with ClientShutdown.
+// Requests after Close fail with ErrConnectionClosed.
 func (conn *BlockConnection) Close() {
 	conn.nbc.Close()
 }
+
+// Shutdown closes the connection to kinetic device gracefully. No new request is accepted, and Shutdown waits
+// for responses of outstanding requests until ctx is done. Then connection is closed, and requests still
+// outstanding fail with ClientShutdown. Returns ctx.Err() if ctx is done before all responses received.
+func (conn *BlockConnection) Shutdown(ctx context.Context) error {
+	return conn.nbc.Shutdown(ctx)
+}
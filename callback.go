@@ -26,6 +26,7 @@ import (
 // Failure is called when XXXXX_RESPONSE message status code is not OK, or any other kind of failure.
 // Done return true if either Success or Failure is called to indicate XXXXX_RESPONSE received and processed.
 // Status return the MessateType operation status.
+// Callback receives raw protobuf messages, use ResponseCallback to receive library types instead.
 type Callback interface {
 	Success(resp *kproto.Command, value []byte)
 	Failure(resp *kproto.Command, status Status)
@@ -63,12 +64,27 @@ type GetCallback struct {
 // store into GetCallback.Entry.
 func (c *GetCallback) Success(resp *kproto.Command, value []byte) {
 	c.GenericCallback.Success(resp, value)
-	c.Entry.Key = resp.GetBody().GetKeyValue().GetKey()
-	c.Entry.Tag = resp.GetBody().GetKeyValue().GetTag()
-	c.Entry.Version = resp.GetBody().GetKeyValue().GetDbVersion()
-	c.Entry.Algo = convertAlgoFromProto(resp.GetBody().GetKeyValue().GetAlgorithm())
+	c.Entry = *getRecordFromProto(resp, value)
+}
+
+// WriteCallback is the Callback for Command_PUT and Command_DELETE Message.
+// When the operation fails with RemoteVersionMismatch, Conflict holds the version
+// of the object on kinetic device, if the device reports it.
+type WriteCallback struct {
+	GenericCallback
+	Conflict *VersionConflictError // Version conflict information, nil if no conflict
+}
 
-	c.Entry.Value = value
+// Failure extracts the version held by kinetic device on RemoteVersionMismatch.
+func (c *WriteCallback) Failure(resp *kproto.Command, status Status) {
+	c.GenericCallback.Failure(resp, status)
+	if status.Code == RemoteVersionMismatch {
+		c.Conflict = &VersionConflictError{
+			Key:            resp.GetBody().GetKeyValue().GetKey(),
+			CurrentVersion: resp.GetBody().GetKeyValue().GetDbVersion(),
+			Status:         status,
+		}
+	}
 }
 
 // GetKeyRangeCallback is the Callback for Command_GETKEYRANGE Message
@@ -104,12 +120,7 @@ type P2PPushCallback struct {
 // Success extracts P2Push operation status from response message.
 func (c *P2PPushCallback) Success(resp *kproto.Command, value []byte) {
 	c.GenericCallback.Success(resp, value)
-	c.P2PStatus.AllOperationsSucceeded = resp.GetBody().GetP2POperation().GetAllChildOperationsSucceeded()
-	c.P2PStatus.PushStatus = make([]Status, len(resp.GetBody().GetP2POperation().GetOperation()))
-	for k, op := range resp.GetBody().GetP2POperation().GetOperation() {
-		c.P2PStatus.PushStatus[k].Code = convertStatusCodeFromProto(op.GetStatus().GetCode())
-		c.P2PStatus.PushStatus[k].ErrorMsg = op.GetStatus().GetStatusMessage()
-	}
+	c.P2PStatus = *getP2PPushStatusFromProto(resp)
 }
 
 // GetLogCallback is the Callback for Command_GETLOG Message
@@ -133,13 +144,43 @@ type BatchEndCallback struct {
 // Success extracts all sequence IDs for commands (PUT/DELETE) performed in batch.
 func (c *BatchEndCallback) Success(resp *kproto.Command, value []byte) {
 	c.GenericCallback.Success(resp, value)
-	c.BatchStatus.DoneSequence = resp.GetBody().GetBatch().GetSequence()
-	c.BatchStatus.FailedSequence = resp.GetBody().GetBatch().GetFailedSequence()
+	c.BatchStatus = *getBatchStatusFromProto(resp)
 }
 
 // Failure extracts the first failed operation sequence in batch.
 func (c *BatchEndCallback) Failure(resp *kproto.Command, status Status) {
 	c.GenericCallback.Failure(resp, status)
-	c.BatchStatus.DoneSequence = resp.GetBody().GetBatch().GetSequence()
-	c.BatchStatus.FailedSequence = resp.GetBody().GetBatch().GetFailedSequence()
+	c.BatchStatus = *getBatchStatusFromProto(resp)
+}
+
+func getRecordFromProto(resp *kproto.Command, value []byte) *Record {
+	kv := resp.GetBody().GetKeyValue()
+	return &Record{
+		Key:      kv.GetKey(),
+		Tag:      kv.GetTag(),
+		Version:  kv.GetDbVersion(),
+		Algo:     convertAlgoFromProto(kv.GetAlgorithm()),
+		Value:    value,
+		MetaOnly: kv.GetMetadataOnly(),
+	}
+}
+
+func getP2PPushStatusFromProto(resp *kproto.Command) *P2PPushStatus {
+	p2p := resp.GetBody().GetP2POperation()
+	status := &P2PPushStatus{
+		AllOperationsSucceeded: p2p.GetAllChildOperationsSucceeded(),
+		PushStatus:             make([]Status, len(p2p.GetOperation())),
+	}
+	for k, op := range p2p.GetOperation() {
+		status.PushStatus[k].Code = convertStatusCodeFromProto(op.GetStatus().GetCode())
+		status.PushStatus[k].ErrorMsg = op.GetStatus().GetStatusMessage()
+	}
+	return status
+}
+
+func getBatchStatusFromProto(resp *kproto.Command) *BatchStatus {
+	return &BatchStatus{
+		DoneSequence:   resp.GetBody().GetBatch().GetSequence(),
+		FailedSequence: resp.GetBody().GetBatch().GetFailedSequence(),
+	}
 }
@@ -0,0 +1,302 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+/*
+Package codec reads and writes framed kinetic protocol messages.
+
+Each message on the wire is a 9 bytes header, the protobuf encoded kproto.Message and the value:
+
+	'F' | message length (4 bytes, big endian) | value length (4 bytes, big endian) | message | value
+
+Reader and Writer work on any io.Reader and io.Writer, compute and verify HMAC of HMACAUTH
+messages, and enforce maximum message and value sizes. They are the building blocks of kinetic
+clients, servers, proxies and sniffers.
+*/
+package codec
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+const (
+	// HeaderSize is the size of frame header.
+	HeaderSize = 9
+	// Magic is the first byte of frame header.
+	Magic byte = 'F'
+)
+
+var (
+	// ErrWrongMagic is returned when frame header doesn't start with Magic.
+	ErrWrongMagic = errors.New("Kinetic frame header wrong magic")
+	// ErrHMACMismatch is returned when HMAC of HMACAUTH message doesn't match.
+	ErrHMACMismatch = errors.New("Kinetic message HMAC mismatch")
+	// ErrUnknownIdentity is returned when HMAC key of HMACAUTH message identity is unknown.
+	ErrUnknownIdentity = errors.New("Kinetic message identity unknown")
+)
+
+// SizeError is returned when message or value of frame exceeds maximum size.
+type SizeError struct {
+	Part string // "message" or "value"
+	Size uint32
+	Max  uint32
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("Kinetic frame %s size %d exceeds maximum %d", e.Part, e.Size, e.Max)
+}
+
+// Limits are maximum sizes of message and value in frame, 0 for no limit.
+type Limits struct {
+	MaxMessageSize uint32
+	MaxValueSize   uint32
+}
+
+func (l Limits) check(h Header) error {
+	if l.MaxMessageSize > 0 && h.MessageSize > l.MaxMessageSize {
+		return &SizeError{Part: "message", Size: h.MessageSize, Max: l.MaxMessageSize}
+	}
+	if l.MaxValueSize > 0 && h.ValueSize > l.MaxValueSize {
+		return &SizeError{Part: "value", Size: h.ValueSize, Max: l.MaxValueSize}
+	}
+	return nil
+}
+
+// Header is the frame header.
+type Header struct {
+	MessageSize uint32
+	ValueSize   uint32
+}
+
+// ParseHeader parses frame header from b, which must be at least HeaderSize long.
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < HeaderSize {
+		return Header{}, io.ErrUnexpectedEOF
+	}
+	if b[0] != Magic {
+		return Header{}, ErrWrongMagic
+	}
+	return Header{
+		MessageSize: binary.BigEndian.Uint32(b[1:5]),
+		ValueSize:   binary.BigEndian.Uint32(b[5:9]),
+	}, nil
+}
+
+// Bytes returns the encoded header.
+func (h Header) Bytes() []byte {
+	b := make([]byte, HeaderSize)
+	b[0] = Magic
+	binary.BigEndian.PutUint32(b[1:5], h.MessageSize)
+	binary.BigEndian.PutUint32(b[5:9], h.ValueSize)
+	return b
+}
+
+// Frame is one kinetic protocol message with its value.
+// Command is decoded from, or encoded into Message.CommandBytes.
+type Frame struct {
+	Message *kproto.Message
+	Command *kproto.Command
+	Value   []byte
+}
+
+// KeyFunc returns HMAC key of identity, false if identity is unknown.
+type KeyFunc func(identity int64) ([]byte, bool)
+
+// StaticKey returns KeyFunc which knows only one identity and its HMAC key.
+func StaticKey(identity int64, key []byte) KeyFunc {
+	return func(id int64) ([]byte, bool) {
+		return key, id == identity
+	}
+}
+
+// ComputeHMAC returns HMAC-SHA1 of command bytes data with key, as defined by kinetic protocol.
+func ComputeHMAC(data []byte, key []byte) []byte {
+	mac := hmac.New(sha1.New, key)
+
+	if len(data) > 0 {
+		ln := make([]byte, 4)
+		binary.BigEndian.PutUint32(ln, uint32(len(data)))
+
+		mac.Write(ln)
+		mac.Write(data)
+	}
+
+	return mac.Sum(nil)
+}
+
+// VerifyHMAC returns true if HMAC of msg matches its command bytes with key.
+func VerifyHMAC(msg *kproto.Message, key []byte) bool {
+	if msg == nil || msg.GetHmacAuth() == nil {
+		return false
+	}
+	return hmac.Equal(ComputeHMAC(msg.GetCommandBytes(), key), msg.GetHmacAuth().GetHmac())
+}
+
+// Reader reads frames from underlying io.Reader.
+type Reader struct {
+	r io.Reader
+
+	// Limits of message and value size, frame exceeding limits is rejected without reading its body.
+	Limits Limits
+	// Key returns HMAC key to verify HMACAUTH messages, nil to skip verification.
+	Key KeyFunc
+}
+
+// NewReader returns Reader reading from r, without limits and HMAC verification.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadHeader reads and parses frame header, and checks limits. If no byte of header is read,
+// error of underlying reader is returned as is, so caller can tell idle read timeout from broken frame.
+func (r *Reader) ReadHeader() (Header, error) {
+	b := make([]byte, HeaderSize)
+	n, err := io.ReadFull(r.r, b)
+	if err != nil {
+		if n == 0 {
+			return Header{}, err
+		}
+		return Header{}, fmt.Errorf("Kinetic frame header truncated, %w", err)
+	}
+	h, err := ParseHeader(b)
+	if err != nil {
+		return Header{}, err
+	}
+	return h, r.Limits.check(h)
+}
+
+// ReadBody reads message and value of frame with header h. Message and Command are decoded, and HMAC
+// of HMACAUTH message is verified if Key is set. The whole frame is read even if HMAC verification
+// fails, so the stream stays in frame; the frame is returned with ErrHMACMismatch or ErrUnknownIdentity.
+func (r *Reader) ReadBody(h Header) (*Frame, error) {
+	// Sizes are checked one by one, so their sum can't overflow int.
+	maxSize := uint64(math.MaxInt)
+	if uint64(h.MessageSize) > maxSize {
+		return nil, &SizeError{Part: "message", Size: h.MessageSize, Max: uint32(maxSize)}
+	}
+	if uint64(h.ValueSize) > maxSize-uint64(h.MessageSize) {
+		return nil, &SizeError{Part: "value", Size: h.ValueSize, Max: uint32(maxSize - uint64(h.MessageSize))}
+	}
+	buf := make([]byte, int(h.MessageSize)+int(h.ValueSize))
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, fmt.Errorf("Kinetic frame body truncated, %w", err)
+	}
+
+	f := &Frame{Message: &kproto.Message{}, Command: &kproto.Command{}}
+	if h.ValueSize > 0 {
+		f.Value = buf[h.MessageSize:]
+	}
+	if err := proto.Unmarshal(buf[:h.MessageSize], f.Message); err != nil {
+		return nil, fmt.Errorf("Kinetic message can't be parsed, %w", err)
+	}
+
+	var authErr error
+	if r.Key != nil && f.Message.GetAuthType() == kproto.Message_HMACAUTH {
+		key, ok := r.Key(f.Message.GetHmacAuth().GetIdentity())
+		switch {
+		case !ok:
+			authErr = ErrUnknownIdentity
+		case !VerifyHMAC(f.Message, key):
+			authErr = ErrHMACMismatch
+		}
+	}
+
+	if err := proto.Unmarshal(f.Message.GetCommandBytes(), f.Command); err != nil {
+		return nil, fmt.Errorf("Kinetic command can't be parsed, %w", err)
+	}
+	return f, authErr
+}
+
+// Read reads one frame, see ReadHeader and ReadBody.
+func (r *Reader) Read() (*Frame, error) {
+	h, err := r.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadBody(h)
+}
+
+// Writer writes frames to underlying io.Writer.
+type Writer struct {
+	w io.Writer
+
+	// Limits of message and value size, frame exceeding limits is not written.
+	Limits Limits
+	// Key returns HMAC key to sign HMACAUTH messages, nil to keep HMAC of message as is.
+	Key KeyFunc
+}
+
+// NewWriter returns Writer writing to w, without limits and HMAC signing.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write encodes Command into Message.CommandBytes if Command is not nil, signs HMACAUTH message
+// with key of its identity if Key is set, and writes the frame with a single Write call.
+func (w *Writer) Write(f *Frame) error {
+	b, err := w.Encode(f)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+	return err
+}
+
+// Encode returns the encoded frame, as Write does without writing it.
+func (w *Writer) Encode(f *Frame) ([]byte, error) {
+	msg := f.Message
+	if f.Command != nil {
+		cmdBytes, err := proto.Marshal(f.Command)
+		if err != nil {
+			return nil, err
+		}
+		msg.CommandBytes = cmdBytes
+	}
+
+	if w.Key != nil && msg.GetAuthType() == kproto.Message_HMACAUTH {
+		if msg.HmacAuth == nil {
+			msg.HmacAuth = &kproto.Message_HMACauth{}
+		}
+		key, ok := w.Key(msg.GetHmacAuth().GetIdentity())
+		if !ok {
+			return nil, ErrUnknownIdentity
+		}
+		msg.HmacAuth.Hmac = ComputeHMAC(msg.CommandBytes, key)
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	h := Header{MessageSize: uint32(len(msgBytes)), ValueSize: uint32(len(f.Value))}
+	if err := w.Limits.check(h); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, HeaderSize+len(msgBytes)+len(f.Value))
+	b = append(b, h.Bytes()...)
+	b = append(b, msgBytes...)
+	return append(b, f.Value...), nil
+}
@@ -0,0 +1,129 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+func newFrame(identity int64, value []byte) *Frame {
+	return &Frame{
+		Message: &kproto.Message{
+			AuthType: kproto.Message_HMACAUTH.Enum(),
+			HmacAuth: &kproto.Message_HMACauth{Identity: proto.Int64(identity)},
+		},
+		Command: &kproto.Command{
+			Header: &kproto.Command_Header{MessageType: kproto.Command_PUT.Enum(), Sequence: proto.Int64(7)},
+		},
+		Value: value,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Key = StaticKey(1, []byte("asdfasdf"))
+	if err := w.Write(newFrame(1, []byte("value"))); err != nil {
+		t.Fatal("Write Failure", err)
+	}
+	if err := w.Write(newFrame(1, nil)); err != nil {
+		t.Fatal("Write Failure", err)
+	}
+
+	r := NewReader(&buf)
+	r.Key = StaticKey(1, []byte("asdfasdf"))
+	f, err := r.Read()
+	if err != nil || !bytes.Equal(f.Value, []byte("value")) || f.Command.GetHeader().GetSequence() != 7 {
+		t.Fatal("Read Failure", err, f)
+	}
+	f, err = r.Read()
+	if err != nil || f.Value != nil {
+		t.Fatal("Read frame without value Failure", err, f)
+	}
+	if _, err = r.Read(); err != io.EOF {
+		t.Fatal("Read at end expect io.EOF", err)
+	}
+}
+
+func TestReadHMACMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Key = StaticKey(1, []byte("wrong"))
+	w.Write(newFrame(1, []byte("value")))
+	w.Key = StaticKey(2, []byte("asdfasdf"))
+	w.Write(newFrame(2, nil))
+	w.Key = StaticKey(1, []byte("asdfasdf"))
+	w.Write(newFrame(1, nil))
+
+	r := NewReader(&buf)
+	r.Key = StaticKey(1, []byte("asdfasdf"))
+	if f, err := r.Read(); err != ErrHMACMismatch || f == nil {
+		t.Fatal("Read expect ErrHMACMismatch with frame", err)
+	}
+	if _, err := r.Read(); err != ErrUnknownIdentity {
+		t.Fatal("Read expect ErrUnknownIdentity", err)
+	}
+	// Stream stays in frame after rejected message
+	if _, err := r.Read(); err != nil {
+		t.Fatal("Read after HMAC mismatch Failure", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Limits = Limits{MaxValueSize: 4}
+	var sizeErr *SizeError
+	if err := w.Write(newFrame(1, []byte("value"))); !errors.As(err, &sizeErr) || sizeErr.Part != "value" {
+		t.Fatal("Write expect value SizeError", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("Frame exceeding limits should not be written")
+	}
+
+	NewWriter(&buf).Write(newFrame(1, []byte("value")))
+	r := NewReader(&buf)
+	r.Limits = Limits{MaxMessageSize: 8}
+	if _, err := r.Read(); !errors.As(err, &sizeErr) || sizeErr.Part != "message" {
+		t.Fatal("Read expect message SizeError", err)
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{'X', 0, 0, 0, 0, 0, 0, 0, 0}))
+	if _, err := r.ReadHeader(); err != ErrWrongMagic {
+		t.Fatal("ReadHeader expect ErrWrongMagic", err)
+	}
+
+	// Error is wrapped if header partially read
+	r = NewReader(bytes.NewReader([]byte{'F', 0, 0}))
+	if _, err := r.ReadHeader(); err == io.ErrUnexpectedEOF || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("ReadHeader expect wrapped io.ErrUnexpectedEOF", err)
+	}
+
+	h := Header{MessageSize: 3, ValueSize: 5}
+	if p, err := ParseHeader(h.Bytes()); err != nil || p != h {
+		t.Fatal("ParseHeader Failure", err, p)
+	}
+}
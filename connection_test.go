@@ -19,13 +19,27 @@ package kinetic
 
 import (
 	"bytes"
-	"os"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/Kinetic/kinetic-go/codec"
+	"github.com/Kinetic/kinetic-go/faultproxy"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
 )
 
 var (
 	blockConn    *BlockConnection
 	nonblockConn *NonBlockConnection
+	tlsPort      = 8443 // For SSL connection
 )
 
 var option = ClientOptions{
@@ -37,16 +51,15 @@ var option = ClientOptions{
 	//UseSSL: true,
 }
 
-func TestMain(m *testing.M) {
-	SetLogLevel(LogLevelDebug)
+// runTests runs tests against kinetic device at option, called by TestMain in package kinetic_test,
+// which can start the simulator. Package simulator imports kinetic, so it can't be started here.
+func runTests(m *testing.M) int {
 	blockConn, _ = NewBlockConnection(option)
-	if blockConn != nil {
-		code := m.Run()
-		blockConn.Close()
-		os.Exit(code)
-	} else {
-		os.Exit(-1)
+	if blockConn == nil {
+		return -1
 	}
+	defer blockConn.Close()
+	return m.Run()
 }
 
 func TestBlockNoOp(t *testing.T) {
@@ -80,6 +93,87 @@ func TestBlockGetPrevious(t *testing.T) {
 	}
 }
 
+func TestBlockGet_requestOptions(t *testing.T) {
+	header := newCommand(kproto.Command_GET).GetHeader()
+	newRequestOptions([]RequestOption{
+		WithTimeout(1500 * time.Millisecond),
+		WithEarlyExit(),
+		WithTimeQuanta(20 * time.Millisecond),
+		WithPriority(PriorityHighest),
+	}).applyHeader(header)
+	if header.GetTimeout() != 1500 || !header.GetEarlyExit() || header.GetTimeQuanta() != 20 ||
+		header.GetPriority() != kproto.Command_HIGHEST {
+		t.Fatal("Request options not applied to command header", header.String())
+	}
+
+	_, status, err := blockConn.Get([]byte("object000"), WithPriority(PriorityHigher), WithTimeout(time.Second))
+	// Object might not exist, expect to see OK status, or RemoteNotFound
+	if err != nil || (status.Code != OK && status.Code != RemoteNotFound) {
+		t.Fatal("Blocking Get with request options Failure", err, status.String())
+	}
+}
+
+func TestBlockGetMeta(t *testing.T) {
+	entry := Record{
+		Key:   []byte("object-meta"),
+		Value: []byte("ABCDEFG"),
+		Sync:  SyncWriteThrough,
+		Algo:  AlgorithmSHA1,
+		Tag:   []byte("tag"),
+		Force: true,
+	}
+	status, err := blockConn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	record, status, err := blockConn.GetMeta(entry.Key)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking GetMeta Failure", err, status.String())
+	}
+	if !record.MetaOnly || record.Value != nil || !bytes.Equal(record.Tag, entry.Tag) || record.Algo != entry.Algo {
+		t.Fatal("Blocking GetMeta returned wrong record", record)
+	}
+
+	_, status, err = blockConn.GetNextMeta([]byte("object000"))
+	// Object might not exist, expect to see OK status, or RemoteNotFound
+	if err != nil || (status.Code != OK && status.Code != RemoteNotFound) {
+		t.Fatal("Blocking GetNextMeta Failure", err, status.String())
+	}
+
+	_, status, err = blockConn.GetPreviousMeta([]byte("object000"))
+	// Object might not exist, expect to see OK status, or RemoteNotFound
+	if err != nil || (status.Code != OK && status.Code != RemoteNotFound) {
+		t.Fatal("Blocking GetPreviousMeta Failure", err, status.String())
+	}
+}
+
+func TestBlockGet_notFoundError(t *testing.T) {
+	_, status, err := blockConn.Get([]byte("object-not-exist"))
+	if err != nil || status.Code != RemoteNotFound {
+		t.Fatal("Blocking Get expect RemoteNotFound", err, status.String())
+	}
+
+	err = status.Err()
+	var kerr *Error
+	if !errors.As(err, &kerr) || kerr.Status.MessageType != MessageGet || kerr.Status.Sequence < 0 {
+		t.Fatal("Expect Error with message type and sequence of failed request", err)
+	}
+	if !errors.Is(err, RemoteNotFound) || errors.Is(err, RemoteServiceBusy) {
+		t.Fatal("Error doesn't match StatusCode", err)
+	}
+	if kerr.Status != status {
+		t.Fatal("Error expect Status of failed request", kerr.Status, status)
+	}
+	if !IsNotFound(err) || IsRetryable(err) || IsAuthorization(err) || IsDeviceState(err) {
+		t.Fatal("Error classification Failure", err)
+	}
+	if IsRetryable(Status{Code: RemoteServiceBusy}.Err()) == false || IsAuthorization(RemoteHMACError) == false ||
+		IsDeviceState(&LimitError{}) || IsDeviceState(RemoteDeviceLocked) == false {
+		t.Fatal("Error classification Failure")
+	}
+}
+
 func TestBlockGetVersion(t *testing.T) {
 	version, status, err := blockConn.GetVersion([]byte("object000"))
 	// Object might not exist, expect to see OK status, or RemoteNotFound
@@ -112,7 +206,6 @@ func TestBlockPut(t *testing.T) {
 }
 
 // TestBlockPut_keyOverflow test key buffer length than MaxKeySize
-// TODO: drive implementation using UNSOLICITEDSTATUS for Key too long.
 func TestBlockPut_keyOverflow(t *testing.T) {
 	entry := Record{
 		Key:   bytes.Repeat([]byte("K"), int(blockConn.nbc.service.device.Limits.MaxKeySize+1)),
@@ -123,14 +216,13 @@ func TestBlockPut_keyOverflow(t *testing.T) {
 		Force: true,
 	}
 	status, err := blockConn.Put(&entry)
-	// Request with key buffer overflow, expect to see failure code RemoteInvalidRequest
-	if err != nil || status.Code != RemoteInvalidRequest {
+	// Request with key buffer overflow, expect to see LimitError before sending request
+	if e, ok := err.(*LimitError); !ok || e.Limit != "MaxKeySize" || status.Code != ClientLimitExceeded {
 		t.Fatal("Blocking Put Failure", err, status.String())
 	}
 }
 
-// TestBlockPut_valueOverflow test key buffer length than MaxValueSize
-// TODO: drive implementation using UNSOLICITEDSTATUS.
+// TestBlockPut_valueOverflow test value buffer length than MaxValueSize
 func TestBlockPut_valueOverflow(t *testing.T) {
 	entry := Record{
 		Key:   []byte("key"),
@@ -141,17 +233,16 @@ func TestBlockPut_valueOverflow(t *testing.T) {
 		Force: true,
 	}
 	status, err := blockConn.Put(&entry)
-	// Request with value buffer overflow, expect to see failure code RemoteInvalidRequest
-	if err != nil || status.Code != RemoteInvalidRequest {
+	// Request with value buffer overflow, expect to see LimitError before sending request
+	if e, ok := err.(*LimitError); !ok || e.Limit != "MaxValueSize" || status.Code != ClientLimitExceeded {
 		t.Fatal("Blocking Put Failure", err, status.String())
 	}
 }
 
-// TestBlockPut_tagOverflow test key buffer length than MaxTagSize
-// TODO: drive implementation using UNSOLICITEDSTATUS.
+// TestBlockPut_tagOverflow test tag buffer length than MaxTagSize
 func TestBlockPut_tagOverflow(t *testing.T) {
 	if blockConn.nbc.service.device.Limits.MaxTagSize > 0xFFFF {
-		t.Skip("Max tag checking not implemented yet, skip this test")
+		t.Skip("Max tag size too large to test, skip this test")
 	}
 	entry := Record{
 		Key:   []byte("key"),
@@ -162,12 +253,24 @@ func TestBlockPut_tagOverflow(t *testing.T) {
 		Force: true,
 	}
 	status, err := blockConn.Put(&entry)
-	// Request with tag buffer overflow, expect to see failure code RemoteInvalidRequest
-	if err != nil || status.Code != RemoteInvalidRequest {
+	// Request with tag buffer overflow, expect to see LimitError before sending request
+	if e, ok := err.(*LimitError); !ok || e.Limit != "MaxTagSize" || status.Code != ClientLimitExceeded {
 		t.Fatal("Blocking Put Failure", err, status.String())
 	}
 }
 
+func TestBlockGetKeyRange_countOverflow(t *testing.T) {
+	r := KeyRange{
+		StartKey: []byte("object000"),
+		EndKey:   []byte("object999"),
+		Max:      int32(blockConn.DeviceLog().Limits.MaxKeyRangeCount + 1),
+	}
+	_, status, err := blockConn.GetKeyRange(&r)
+	if e, ok := err.(*LimitError); !ok || e.Limit != "MaxKeyRangeCount" || status.Code != ClientLimitExceeded {
+		t.Fatal("Blocking GetKeyRange expect LimitError", err, status.String())
+	}
+}
+
 func TestBlockDelete(t *testing.T) {
 	entry := Record{
 		Key:   []byte("object000"),
@@ -181,6 +284,57 @@ func TestBlockDelete(t *testing.T) {
 	}
 }
 
+func TestBlockPutDelete_versionConflict(t *testing.T) {
+	entry := Record{
+		Key:        []byte("object-cas"),
+		Value:      []byte("ABCDEFG"),
+		NewVersion: []byte("v1"),
+		Sync:       SyncWriteThrough,
+		Algo:       AlgorithmSHA1,
+		Force:      true,
+	}
+	status, err := blockConn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	// Put with wrong version, expect to see VersionConflictError with device version
+	entry.Force = false
+	entry.Version = []byte("v0")
+	entry.NewVersion = []byte("v2")
+	status, err = blockConn.Put(&entry)
+	conflict, ok := err.(*VersionConflictError)
+	if !ok || status.Code != RemoteVersionMismatch {
+		t.Fatal("Blocking Put with wrong version Failure", err, status.String())
+	}
+	if !bytes.Equal(conflict.ExpectedVersion, []byte("v0")) || !bytes.Equal(conflict.CurrentVersion, []byte("v1")) {
+		t.Fatal("Blocking Put version conflict information wrong", conflict.Error())
+	}
+
+	entry.Version = []byte("v1")
+	status, err = blockConn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put with version Failure", err, status.String())
+	}
+
+	// Delete with previous version, expect to see VersionConflictError
+	del := Record{
+		Key:     entry.Key,
+		Version: []byte("v1"),
+		Sync:    SyncWriteThrough,
+	}
+	status, err = blockConn.Delete(&del)
+	if _, ok := err.(*VersionConflictError); !ok || status.Code != RemoteVersionMismatch {
+		t.Fatal("Blocking Delete with wrong version Failure", err, status.String())
+	}
+
+	del.Version = []byte("v2")
+	status, err = blockConn.Delete(&del)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Delete with version Failure", err, status.String())
+	}
+}
+
 func TestBlockGetKeyRange(t *testing.T) {
 	r := KeyRange{
 		StartKey:          []byte("object000"),
@@ -228,6 +382,22 @@ func TestBlockGetLogLimit(t *testing.T) {
 	}
 }
 
+func TestBlockDeviceLog(t *testing.T) {
+	device := blockConn.DeviceLog()
+	logs, status, err := blockConn.GetLog([]LogType{LogTypeConfiguration, LogTypeLimits})
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking GetLog Failure", err, status.String())
+	}
+	if !bytes.Equal(device.Configuration.WorldWideName, logs.Configuration.WorldWideName) ||
+		device.Limits.MaxValueSize != logs.Limits.MaxValueSize {
+		t.Fatal("Handshake device log mismatch with GetLog", device.Configuration, device.Limits)
+	}
+	if blockConn.ConnectionID() < 0 {
+		t.Fatal("Invalid connection ID", blockConn.ConnectionID())
+	}
+	t.Logf("Connection ID = %d, cluster version = %d", blockConn.ConnectionID(), blockConn.ClientClusterVersion())
+}
+
 func TestBlockGetLogAll(t *testing.T) {
 	logs := []LogType{
 		LogTypeUtilizations,
@@ -298,11 +468,22 @@ func TestBlockSetClusterVersion(t *testing.T) {
 	}
 
 	blockConn.SetClientClusterVersion(2)
+	if blockConn.ClientClusterVersion() != 2 {
+		t.Fatal("Blocking ClientClusterVersion expected 2, actual", blockConn.ClientClusterVersion())
+	}
 	_, status, err = blockConn.Get([]byte("object000"))
 	if err != nil || status.Code != RemoteClusterVersionMismatch {
 		t.Fatal("Blocking Get expected RemoteClusterVersionMismatch. ", err, status.String())
 	}
 	t.Log(status.String())
+
+	// Restore cluster version for following tests
+	blockConn.SetClientClusterVersion(1)
+	status, err = blockConn.SetClusterVersion(0)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking SetClusterVersion Failure: ", err, status.String())
+	}
+	blockConn.SetClientClusterVersion(0)
 }
 
 func TestBlockInstantErase(t *testing.T) {
@@ -336,3 +517,1085 @@ func TestBlockSetLockPin(t *testing.T) {
 		t.Fatal("Blocking SetLockPin Failure: ", err, status.String())
 	}
 }
+
+func TestNonBlockPipeline(t *testing.T) {
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("NonBlock connection Failure", err)
+	}
+	defer conn.Close()
+
+	const count = 100
+	callbacks := make([]*GenericCallback, count)
+	handlers := make([]*ResponseHandler, count)
+	for k := 0; k < count; k++ {
+		entry := Record{
+			Key:   []byte(fmt.Sprintf("pipeline%03d", k)),
+			Value: []byte("ABCDEFG"),
+			Sync:  SyncWriteBack,
+			Algo:  AlgorithmSHA1,
+			Force: true,
+		}
+		callbacks[k] = &GenericCallback{}
+		handlers[k] = NewResponseHandler(callbacks[k])
+		err = conn.Put(&entry, handlers[k])
+		if err != nil {
+			t.Fatal("NonBlock Put Failure", err)
+		}
+	}
+
+	// Responses are dispatched to their handler regardless of Listen order.
+	for k := count - 1; k >= 0; k-- {
+		err = conn.Listen(handlers[k])
+		if err != nil || callbacks[k].Status().Code != OK {
+			t.Fatal("NonBlock Put Failure", k, err, callbacks[k].Status().String())
+		}
+	}
+}
+
+func TestBlockGet_contextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, status, err := blockConn.Get([]byte("object000"), WithContext(ctx))
+	if err != context.Canceled || status.Code != ClientRequestCanceled {
+		t.Fatal("Blocking Get with canceled context expect context.Canceled", err, status.String())
+	}
+
+	// Cancel while request outstanding, response may or may not arrive before cancellation.
+	ctx, cancel = context.WithCancel(context.Background())
+	callback := &GetCallback{}
+	h := NewResponseHandler(callback)
+	err = blockConn.nbc.Get([]byte("object000"), h, WithContext(ctx))
+	if err != nil {
+		t.Fatal("NonBlock Get Failure", err)
+	}
+	cancel()
+	err = blockConn.nbc.Listen(h)
+	if err != nil && err != context.Canceled {
+		t.Fatal("NonBlock Get expect context.Canceled", err, callback.Status().String())
+	}
+
+	// Connection still usable after cancellation
+	status, err = blockConn.NoOp()
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp Failure after context canceled", err, status.String())
+	}
+}
+
+func TestBlockReconnect(t *testing.T) {
+	op := option
+	op.Reconnect = &ReconnectPolicy{MaxAttempts: 10, InitialDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	conn.SetClientClusterVersion(5)
+
+	// Break the network connection, expect connection reconnects to device
+	conn.nbc.service.mapMu.Lock()
+	conn.nbc.service.conn.Close()
+	conn.nbc.service.mapMu.Unlock()
+
+	var status Status
+	for k := 0; k < 50; k++ {
+		_, status, err = conn.Get([]byte("object000"))
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Client cluster version restored after reconnect
+	if err != nil || status.Code != RemoteClusterVersionMismatch {
+		t.Fatal("Blocking Get after reconnect expected RemoteClusterVersionMismatch", err, status.String())
+	}
+}
+
+func TestNonBlockReconnect_resubmitReads(t *testing.T) {
+	entry := Record{Key: []byte("resubmit-object"), Value: []byte("value"), Sync: SyncWriteThrough, Force: true}
+	if status, err := blockConn.Put(&entry); err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	proxy, op := startFaultProxy(t)
+	op.Reconnect = &ReconnectPolicy{MaxAttempts: 10, InitialDelay: 10 * time.Millisecond, ResubmitReads: true}
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// Responses of the first GET and PUT never arrive, connection breaks while both in flight
+	proxy.Inject(faultproxy.Rule{Match: faultproxy.All(faultproxy.Response(kproto.Command_GET_RESPONSE), faultproxy.Sequence(0)), Fault: faultproxy.Drop()})
+	proxy.Inject(faultproxy.Rule{Match: faultproxy.All(faultproxy.Response(kproto.Command_PUT_RESPONSE), faultproxy.Sequence(1)), Fault: faultproxy.Drop()})
+
+	getCallback := &GetCallback{}
+	getHandler := NewResponseHandler(getCallback)
+	putCallback := &GenericCallback{}
+	putHandler := NewResponseHandler(putCallback)
+	conn.Get(entry.Key, getHandler)
+	conn.Put(&entry, putHandler)
+	proxy.Disconnect()
+
+	// GET is resubmitted after reconnected, PUT may have been applied and fails
+	if err := conn.Listen(getHandler); err != nil || getCallback.Status().Code != OK || !bytes.Equal(getCallback.Entry.Value, entry.Value) {
+		t.Fatal("Nonblocking Get expect resubmitted after reconnect", err, getCallback.Status().String())
+	}
+	if err := conn.Listen(putHandler); !errors.Is(err, ClientConnectionReset) || putCallback.Status().Code != ClientConnectionReset {
+		t.Fatal("Nonblocking Put expect ClientConnectionReset", err, putCallback.Status().String())
+	}
+}
+
+func TestBlockReconnect_failFast(t *testing.T) {
+	proxy, op := startFaultProxy(t)
+	op.Reconnect = &ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+
+	// Device unreachable, connection keeps reconnecting
+	proxy.Close()
+	for !conn.nbc.service.isReconnecting() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Requests fail immediately while reconnecting, instead of waiting for reconnect
+	start := time.Now()
+	status, err := conn.NoOp()
+	if !errors.Is(err, ClientConnectionReset) || status.Code != ClientConnectionReset || time.Since(start) > time.Second {
+		t.Fatal("Blocking NoOp while reconnecting expect ClientConnectionReset", err, status.String(), time.Since(start))
+	}
+
+	// Close stops reconnecting
+	conn.Close()
+}
+
+func TestBlockIdleTimeout(t *testing.T) {
+	op := option
+	op.IdleTimeout = 100
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// Per request timeout, longer than idle timeout
+	status, err := conn.NoOp(WithRequestTimeout(time.Second))
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp Failure", err, status.String())
+	}
+	if conn.nbc.service.timeouts.idle != 100*time.Millisecond || blockConn.nbc.service.timeouts.idle != 0 {
+		t.Fatal("Connection timeouts should not be shared")
+	}
+
+	// Connection closed after idle timeout
+	select {
+	case <-conn.nbc.service.closing:
+	case <-time.After(time.Second):
+		t.Fatal("Connection expected closed after idle timeout")
+	}
+	status, err = conn.NoOp()
+	if err == nil {
+		t.Fatal("Blocking NoOp expected failure after idle timeout", status.String())
+	}
+}
+
+func TestTLSVerification(t *testing.T) {
+	op := option
+	op.Port = tlsPort
+	op.UseSSL = true
+
+	// Get device certificate fingerprint
+	c, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", op.Host, op.Port), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal("SSL connection Failure", err)
+	}
+	fingerprint := CertificateFingerprint(c.ConnectionState().PeerCertificates[0])
+	c.Close()
+
+	op.TLS = &TLSOptions{InsecureSkipVerify: true, PinnedFingerprints: [][]byte{fingerprint}}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("SSL connection with pinned fingerprint Failure", err)
+	}
+	status, err := conn.NoOp()
+	conn.Close()
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp over SSL Failure", err, status.String())
+	}
+
+	op.TLS = &TLSOptions{InsecureSkipVerify: true, PinnedFingerprints: [][]byte{make([]byte, len(fingerprint))}}
+	_, err = NewBlockConnection(op)
+	if _, ok := err.(*TLSVerificationError); !ok {
+		t.Fatal("SSL connection with wrong fingerprint expect TLSVerificationError", err)
+	}
+
+	op.TLS = &TLSOptions{RootCAs: x509.NewCertPool()}
+	_, err = NewBlockConnection(op)
+	if _, ok := err.(*TLSVerificationError); !ok {
+		t.Fatal("SSL connection with unknown authority expect TLSVerificationError", err)
+	}
+}
+
+// writeUnsolicitedStatus sends UNSOLICITEDSTATUS message with cmd, as kinetic device does.
+func writeUnsolicitedStatus(c net.Conn, cmd *kproto.Command) error {
+	return codec.NewWriter(c).Write(&codec.Frame{
+		Message: &kproto.Message{AuthType: kproto.Message_UNSOLICITEDSTATUS.Enum()},
+		Command: cmd,
+	})
+}
+
+// startFakeDevice starts a fake kinetic device on ephemeral port, which does the handshake then calls serve.
+// Returns ClientOptions to connect to the fake device.
+func startFakeDevice(t *testing.T, serve func(c net.Conn)) ClientOptions {
+	return startFakeDeviceWithLimits(t, &kproto.Command_GetLog_Limits{}, serve)
+}
+
+// startFakeDeviceWithLimits starts a fake kinetic device as startFakeDevice, which reports limits in handshake.
+func startFakeDeviceWithLimits(t *testing.T, limits *kproto.Command_GetLog_Limits, serve func(c net.Conn)) ClientOptions {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen Failure", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		writeUnsolicitedStatus(c, &kproto.Command{
+			Header: &kproto.Command_Header{ConnectionID: proto.Int64(1)},
+			Body: &kproto.Command_Body{GetLog: &kproto.Command_GetLog{
+				Configuration: &kproto.Command_GetLog_Configuration{},
+				Limits:        limits,
+			}},
+		})
+		serve(c)
+	}()
+
+	op := option
+	op.Port = ln.Addr().(*net.TCPAddr).Port
+	return op
+}
+
+// readRequest reads one request message from client, returns the command.
+func readRequest(c net.Conn) (*kproto.Command, error) {
+	f, err := codec.NewReader(c).Read()
+	if err != nil {
+		return nil, err
+	}
+	return f.Command, nil
+}
+
+// writeResponse sends successful response message for NOOP request, as kinetic device does.
+func writeResponse(c net.Conn, req *kproto.Command) error {
+	w := codec.NewWriter(c)
+	w.Key = codec.StaticKey(option.User, option.Hmac)
+	return w.Write(&codec.Frame{
+		Message: &kproto.Message{
+			AuthType: kproto.Message_HMACAUTH.Enum(),
+			HmacAuth: &kproto.Message_HMACauth{Identity: proto.Int64(option.User)},
+		},
+		Command: &kproto.Command{
+			Header: &kproto.Command_Header{
+				AckSequence: proto.Int64(req.GetHeader().GetSequence()),
+				MessageType: kproto.Command_NOOP_RESPONSE.Enum(),
+			},
+			Status: &kproto.Command_Status{Code: kproto.Command_Status_SUCCESS.Enum()},
+		},
+	})
+}
+
+func TestBlockUnsolicitedStatus(t *testing.T) {
+	// Fake kinetic device, reads one request then shuts down without response.
+	op := startFakeDevice(t, func(c net.Conn) {
+		readRequest(c)
+		writeUnsolicitedStatus(c, &kproto.Command{Status: &kproto.Command_Status{
+			Code: kproto.Command_Status_SERVICE_BUSY.Enum(), StatusMessage: proto.String("busy")}})
+		writeUnsolicitedStatus(c, &kproto.Command{Status: &kproto.Command_Status{
+			Code: kproto.Command_Status_SHUTDOWN.Enum(), StatusMessage: proto.String("shutting down")}})
+	})
+
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	events := make(chan UnsolicitedStatus, 2)
+	conn.SubscribeUnsolicitedStatus(func(s UnsolicitedStatus) { events <- s })
+
+	// Outstanding request fails with the terminal status
+	status, err := conn.NoOp()
+	if err == nil || status.Code != RemoteShutdown {
+		t.Fatal("Blocking NoOp expect RemoteShutdown", err, status.String())
+	}
+
+	e := <-events
+	if e.Terminal || e.Status.Code != RemoteServiceBusy {
+		t.Fatal("Expect non terminal RemoteServiceBusy unsolicited status", e)
+	}
+	e = <-events
+	if !e.Terminal || e.Status.Code != RemoteShutdown || e.Status.ErrorMsg != "shutting down" {
+		t.Fatal("Expect terminal RemoteShutdown unsolicited status", e)
+	}
+
+	// Connection is closed after terminal status
+	status, err = conn.NoOp()
+	if err == nil {
+		t.Fatal("Blocking NoOp after device shutdown expect failure", status.String())
+	}
+}
+
+func TestBlockUnsolicitedStatus_reconnect(t *testing.T) {
+	proxy, op := startFaultProxy(t)
+	op.Reconnect = &ReconnectPolicy{MaxAttempts: 10, InitialDelay: 10 * time.Millisecond}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	events := make(chan UnsolicitedStatus, 1)
+	conn.SubscribeUnsolicitedStatus(func(s UnsolicitedStatus) { events <- s })
+	connID := conn.ConnectionID()
+
+	// Device hibernates, connection reconnects instead of closing
+	proxy.SendUnsolicitedStatus(kproto.Command_Status_HIBERNATE, "hibernate")
+	if e := <-events; !e.Terminal || e.Status.Code != RemoteHibernate {
+		t.Fatal("Expect terminal RemoteHibernate unsolicited status", e)
+	}
+
+	var status Status
+	for k := 0; k < 50; k++ {
+		status, err = conn.NoOp()
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp after reconnect Failure", err, status.String())
+	}
+	if conn.ConnectionID() == connID {
+		t.Fatal("Expect new connection after terminal unsolicited status")
+	}
+}
+
+func TestBlockRetryPolicy(t *testing.T) {
+	conn := &BlockConnection{retry: &RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, Jitter: 0.5}}
+
+	attempts := 0
+	busy := func() (Status, error) {
+		attempts++
+		if attempts < 3 {
+			return Status{Code: RemoteServiceBusy}, nil
+		}
+		return Status{Code: OK}, nil
+	}
+	status, err := conn.withRetry(MessageGet, true, nil, busy)
+	if err != nil || status.Code != OK || attempts != 3 {
+		t.Fatal("Get expect success after retry", err, status.String(), attempts)
+	}
+
+	// Write without version precondition is never retried
+	attempts = 0
+	status, err = conn.withRetry(MessagePut, false, nil, busy)
+	if err != nil || status.Code != RemoteServiceBusy || attempts != 1 {
+		t.Fatal("Put without precondition expect no retry", err, status.String(), attempts)
+	}
+
+	// Client side transient error is retried until attempts exhausted
+	attempts = 0
+	ioError := func() (Status, error) {
+		attempts++
+		s := Status{Code: ClientIOError}
+		return s, s.Err()
+	}
+	status, err = conn.withRetry(MessageGet, true, nil, ioError)
+	if !errors.Is(err, ClientIOError) || attempts != 3 {
+		t.Fatal("Get expect retry on ClientIOError", err, attempts)
+	}
+
+	// Write may have been applied on client side error, not retried even with precondition
+	attempts = 0
+	status, err = conn.withRetry(MessagePut, true, nil, ioError)
+	if !errors.Is(err, ClientIOError) || attempts != 1 {
+		t.Fatal("Put with precondition expect no retry on ClientIOError", err, attempts)
+	}
+	if hasPrecondition(&Record{Force: true}) || hasPrecondition(&Record{Version: []byte("v1"), Force: true}) ||
+		!hasPrecondition(&Record{Version: []byte("v1")}) {
+		t.Fatal("Write precondition expect Record.Force not set")
+	}
+
+	// Create-only write, without Force and Version, is conditional: retried on busy, not on ambiguous failure
+	create := &Record{Key: []byte("create-only")}
+	attempts = 0
+	status, err = conn.withRetry(MessagePut, hasPrecondition(create), nil, busy)
+	if err != nil || status.Code != OK || attempts != 3 {
+		t.Fatal("Create-only Put expect retry on RemoteServiceBusy", err, status.String(), attempts)
+	}
+	attempts = 0
+	status, err = conn.withRetry(MessagePut, hasPrecondition(create), nil, ioError)
+	if !errors.Is(err, ClientIOError) || attempts != 1 {
+		t.Fatal("Create-only Put expect no retry on ClientIOError", err, attempts)
+	}
+
+	// Status not retryable
+	attempts = 0
+	status, err = conn.withRetry(MessageGet, true, nil, func() (Status, error) {
+		attempts++
+		return Status{Code: RemoteNotFound}, nil
+	})
+	if status.Code != RemoteNotFound || attempts != 1 {
+		t.Fatal("Get expect no retry on RemoteNotFound", err, status.String(), attempts)
+	}
+
+	p := RetryPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, Jitter: 0.2}
+	if d := p.delay(1); d < 8*time.Millisecond || d > 12*time.Millisecond {
+		t.Fatal("First retry delay out of range", d)
+	}
+	if d := p.delay(10); d < 32*time.Millisecond || d > 48*time.Millisecond {
+		t.Fatal("Retry delay expect capped by MaxDelay", d)
+	}
+}
+
+func TestNonBlockShutdown(t *testing.T) {
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+
+	callbacks := make([]*GenericCallback, 10)
+	handlers := make([]*ResponseHandler, len(callbacks))
+	for k := range callbacks {
+		callbacks[k] = &GenericCallback{}
+		handlers[k] = NewResponseHandler(callbacks[k])
+		if err := conn.NoOp(handlers[k]); err != nil {
+			t.Fatal("Nonblocking NoOp Failure", err)
+		}
+	}
+
+	// Outstanding requests drained before connection closed
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.Shutdown(ctx); err != nil {
+		t.Fatal("Shutdown Failure", err)
+	}
+	for k, h := range handlers {
+		if err := conn.Listen(h); err != nil || callbacks[k].Status().Code != OK {
+			t.Fatal("Nonblocking NoOp expect drained by Shutdown", err, callbacks[k].Status().String())
+		}
+	}
+
+	callback := &GenericCallback{}
+	err = conn.NoOp(NewResponseHandler(callback))
+	if err != ErrConnectionClosed || callback.Status().Code != ClientShutdown {
+		t.Fatal("Nonblocking NoOp after Shutdown expect ErrConnectionClosed", err, callback.Status().String())
+	}
+}
+
+func TestNonBlockClose_outstanding(t *testing.T) {
+	// Fake kinetic device never responds.
+	op := startFakeDevice(t, func(c net.Conn) {
+		for {
+			if _, err := readRequest(c); err != nil {
+				return
+			}
+		}
+	})
+
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	callback := &GenericCallback{}
+	h := NewResponseHandler(callback)
+	if err := conn.NoOp(h); err != nil {
+		t.Fatal("Nonblocking NoOp Failure", err)
+	}
+
+	// Shutdown gives up after deadline, outstanding request fails with ClientShutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := conn.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatal("Shutdown expect deadline exceeded", err)
+	}
+	if err := conn.Listen(h); !errors.Is(err, ClientShutdown) || callback.Status().Code != ClientShutdown {
+		t.Fatal("Outstanding request expect ClientShutdown", err, callback.Status().String())
+	}
+	conn.Close()
+}
+
+func TestNonBlockRequestTimeout(t *testing.T) {
+	// Fake kinetic device, never responds to the first request.
+	op := startFakeDevice(t, func(c net.Conn) {
+		for k := 0; ; k++ {
+			req, err := readRequest(c)
+			if err != nil {
+				return
+			}
+			if k > 0 {
+				writeResponse(c, req)
+			}
+		}
+	})
+
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	callbacks := []*GenericCallback{{}, {}, {}}
+	handlers := make([]*ResponseHandler, len(callbacks))
+	for k := range callbacks {
+		handlers[k] = NewResponseHandler(callbacks[k])
+	}
+	conn.NoOp(handlers[0], WithRequestTimeout(100*time.Millisecond))
+	conn.NoOp(handlers[1], WithRequestTimeout(5*time.Second))
+
+	if err := conn.Listen(handlers[1]); err != nil || callbacks[1].Status().Code != OK {
+		t.Fatal("Nonblocking NoOp Failure", err, callbacks[1].Status().String())
+	}
+
+	// Expired request alone fails, connection keeps working
+	if err := conn.Listen(handlers[0]); !errors.Is(err, ClientRequestTimeout) || callbacks[0].Status().Code != ClientRequestTimeout {
+		t.Fatal("Nonblocking NoOp expect ClientRequestTimeout", err, callbacks[0].Status().String())
+	}
+	conn.NoOp(handlers[2])
+	if err := conn.Listen(handlers[2]); err != nil || callbacks[2].Status().Code != OK {
+		t.Fatal("Nonblocking NoOp after request timeout Failure", err, callbacks[2].Status().String())
+	}
+	conn.service.mapMu.Lock()
+	outstanding := len(conn.service.hmap)
+	conn.service.mapMu.Unlock()
+	if outstanding != 0 {
+		t.Fatal("Expired ResponseHandler should be removed")
+	}
+}
+
+func TestBlockRequestTimeout_releaseSlot(t *testing.T) {
+	const lost = 5
+	// Fake kinetic device allows 2 outstanding read requests, never responds to the first requests.
+	limits := &kproto.Command_GetLog_Limits{MaxOutstandingReadRequests: proto.Uint32(2)}
+	op := startFakeDeviceWithLimits(t, limits, func(c net.Conn) {
+		for k := 0; ; k++ {
+			req, err := readRequest(c)
+			if err != nil {
+				return
+			}
+			if k >= lost {
+				writeResponse(c, req)
+			}
+		}
+	})
+	op.FlowControl = FlowControlFailFast
+
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// Expired requests release their slot, though response never arrives
+	for k := 0; k < lost; k++ {
+		if status, _ := conn.NoOp(WithRequestTimeout(50 * time.Millisecond)); status.Code != ClientRequestTimeout {
+			t.Fatal("Blocking NoOp expect ClientRequestTimeout", k, status.String())
+		}
+	}
+	if status, err := conn.NoOp(); err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp after lost responses Failure", err, status.String())
+	}
+}
+
+func TestValidate_messageSize(t *testing.T) {
+	cmd := newCommand(kproto.Command_PUT)
+	cmd.Body = &kproto.Command_Body{KeyValue: &kproto.Command_KeyValue{Key: []byte("key")}}
+	msg := newMessage(kproto.Message_HMACAUTH)
+	size := proto.Size(cmd)
+
+	// Connection ID, sequence and cluster version are set after validate, request must leave room for them
+	if err := validate(&LimitsLog{MaxMessageSize: uint32(size)}, msg, cmd, nil); err == nil {
+		t.Fatal("Request without room for header fields expect LimitError")
+	}
+	limits := &LimitsLog{MaxMessageSize: uint32(size + requestHeaderAllowance)}
+	if err := validate(limits, msg, cmd, nil); err != nil {
+		t.Fatal("Request within MaxMessageSize Failure", err)
+	}
+	connID, seq, clusterVersion := int64(-1), int64(math.MaxInt64), int64(math.MinInt64)
+	cmd.Header.ConnectionID, cmd.Header.Sequence, cmd.Header.ClusterVersion = &connID, &seq, &clusterVersion
+	if proto.Size(cmd) > int(limits.MaxMessageSize) {
+		t.Fatal("Request exceeds MaxMessageSize after header fields set", proto.Size(cmd), limits.MaxMessageSize)
+	}
+}
+
+func TestBlockResponseLimits(t *testing.T) {
+	// Fake kinetic device allows 1024 bytes value, responds with header of value size beyond its limit.
+	limits := &kproto.Command_GetLog_Limits{MaxValueSize: proto.Uint32(1024)}
+	op := startFakeDeviceWithLimits(t, limits, func(c net.Conn) {
+		if _, err := readRequest(c); err != nil {
+			return
+		}
+		c.Write(codec.Header{ValueSize: 0xFFFFFFFF}.Bytes())
+		readRequest(c)
+	})
+
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	if status, _ := conn.NoOp(); status.Code != ClientIOError {
+		t.Fatal("Blocking NoOp with oversized response expect ClientIOError", status.String())
+	}
+
+	l := responseLimits(ClientOptions{}, &LimitsLog{MaxMessageSize: 1024, MaxValueSize: 1024, MaxKeySize: 4096, MaxKeyRangeCount: 200})
+	if l.MaxMessageSize != 4096*200+responseSlack || l.MaxValueSize != 1024+responseSlack {
+		t.Fatal("Response limits from device limits mismatch", l)
+	}
+	l = responseLimits(ClientOptions{MaxResponseMessageSize: 1, MaxResponseValueSize: 2}, &LimitsLog{MaxValueSize: 1024})
+	if l.MaxMessageSize != 1 || l.MaxValueSize != 2 {
+		t.Fatal("Response limits override mismatch", l)
+	}
+}
+
+func TestBlockConcurrent(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	const goroutines = 16
+	const count = 20
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			for k := 0; k < count; k++ {
+				entry := Record{
+					Key:   []byte(fmt.Sprintf("concurrent%02d-%02d", g, k)),
+					Value: []byte(fmt.Sprintf("value%02d-%02d", g, k)),
+					Sync:  SyncWriteBack,
+					Algo:  AlgorithmSHA1,
+					Force: true,
+				}
+				if status, err := conn.Put(&entry); err != nil || status.Code != OK {
+					errs <- fmt.Errorf("Put %s failure, %v, %s", entry.Key, err, status.String())
+					return
+				}
+				// Each goroutine gets response of its own request
+				record, status, err := conn.Get(entry.Key)
+				if err != nil || status.Code != OK || !bytes.Equal(record.Value, entry.Value) {
+					errs <- fmt.Errorf("Get %s failure, %v, %s", entry.Key, err, status.String())
+					return
+				}
+				if status, err := conn.Delete(&entry); err != nil || status.Code != OK {
+					errs <- fmt.Errorf("Delete %s failure, %v, %s", entry.Key, err, status.String())
+					return
+				}
+				if status, err := conn.NoOp(); err != nil || status.Code != OK {
+					errs <- fmt.Errorf("NoOp failure, %v, %s", err, status.String())
+					return
+				}
+			}
+			errs <- nil
+		}(g)
+	}
+
+	// Batch and connection settings changed concurrently with requests
+	conn.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2})
+	if status, err := conn.BatchStart(); err != nil || status.Code != OK {
+		t.Fatal("Blocking BatchStart Failure", err, status.String())
+	}
+	entry := Record{Key: []byte("concurrent-batch"), Value: []byte("batch"), Algo: AlgorithmSHA1, Force: true}
+	if err := conn.BatchPut(&entry); err != nil {
+		t.Fatal("Blocking BatchPut Failure", err)
+	}
+	if _, status, err := conn.BatchEnd(); err != nil || status.Code != OK {
+		t.Fatal("Blocking BatchEnd Failure", err, status.String())
+	}
+
+	for g := 0; g < goroutines; g++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNonBlockFuture(t *testing.T) {
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	puts := make([]*Future[Status], 10)
+	for k := range puts {
+		entry := Record{
+			Key:   []byte(fmt.Sprintf("future%02d", k)),
+			Value: []byte(fmt.Sprintf("value%02d", k)),
+			Algo:  AlgorithmSHA1,
+			Force: true,
+		}
+		puts[k] = conn.PutAsync(&entry)
+	}
+	for k, f := range puts {
+		if status, err := f.Wait(ctx); err != nil || status.Code != OK {
+			t.Fatal("PutAsync Failure", k, err, status.String())
+		}
+	}
+
+	get := conn.GetAsync([]byte("future03"))
+	keys := conn.GetKeyRangeAsync(&KeyRange{StartKey: []byte("future00"), EndKey: []byte("future09"),
+		StartKeyInclusive: true, EndKeyInclusive: true, Max: 100})
+	select {
+	case <-get.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetAsync not done")
+	}
+	record, err := get.Wait(ctx)
+	if err != nil || string(record.Value) != "value03" {
+		t.Fatal("GetAsync Failure", err)
+	}
+	if list, err := keys.Wait(ctx); err != nil || len(list) != 10 {
+		t.Fatal("GetKeyRangeAsync Failure", err, len(list))
+	}
+
+	// Failure status is returned as error
+	if _, err := conn.GetAsync([]byte("future-not-exist")).Wait(ctx); !IsNotFound(err) {
+		t.Fatal("GetAsync expect RemoteNotFound", err)
+	}
+	entry := Record{Key: []byte("future00"), Value: []byte("value"), Version: []byte("wrong"), Algo: AlgorithmSHA1}
+	conflict := conn.PutAsync(&entry)
+	meta := conn.GetNextMetaAsync([]byte("future00"))
+
+	// Wait can be called concurrently, all get the same result
+	var wg sync.WaitGroup
+	for k := 0; k < 4; k++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := conflict.Wait(ctx); !errors.As(err, new(*VersionConflictError)) {
+				t.Error("PutAsync expect VersionConflictError", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if record, err := meta.Wait(ctx); err != nil || string(record.Key) != "future01" || record.Value != nil {
+				t.Error("GetNextMetaAsync Failure", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Failed batch returns the first failed job sequence with the error
+	if _, err := conn.BatchStartAsync().Wait(ctx); err != nil {
+		t.Fatal("BatchStartAsync Failure", err)
+	}
+	if err := conn.BatchPut(&entry); err != nil {
+		t.Fatal("BatchPut Failure", err)
+	}
+	if batch, err := conn.BatchEndAsync().Wait(ctx); err == nil || batch == nil || batch.FailedSequence == 0 {
+		t.Fatal("BatchEndAsync expect failed sequence with error", err, batch)
+	}
+
+	for k := range puts {
+		entry := Record{Key: []byte(fmt.Sprintf("future%02d", k)), Force: true}
+		puts[k] = conn.DeleteAsync(&entry)
+	}
+	for k, f := range puts {
+		if _, err := f.Wait(ctx); err != nil {
+			t.Fatal("DeleteAsync Failure", k, err)
+		}
+	}
+}
+
+// recordingCallback is ResponseCallback which keeps the Response.
+type recordingCallback struct {
+	resp    *Response
+	success bool
+}
+
+func (c *recordingCallback) Success(resp *Response) {
+	c.resp, c.success = resp, true
+}
+
+func (c *recordingCallback) Failure(resp *Response) {
+	c.resp, c.success = resp, false
+}
+
+func TestNonBlockResponseCallback(t *testing.T) {
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	entry := Record{Key: []byte("callback00"), Value: []byte("ABCDEFG"), Tag: []byte("tag"), Algo: AlgorithmSHA1, Force: true}
+	callback := &recordingCallback{}
+	h := NewResponseCallbackHandler(callback)
+	if err = conn.Put(&entry, h); err == nil {
+		err = conn.Listen(h)
+	}
+	if err != nil || !callback.success || callback.resp.MessageType != MessagePut || callback.resp.Status.Code != OK {
+		t.Fatal("Nonblocking Put with ResponseCallback Failure", err, callback.resp)
+	}
+
+	callback = &recordingCallback{}
+	h = NewResponseCallbackHandler(callback)
+	if err = conn.Get(entry.Key, h); err == nil {
+		err = conn.Listen(h)
+	}
+	if err != nil || !callback.success || callback.resp.MessageType != MessageGet || callback.resp.Sequence < 0 ||
+		!bytes.Equal(callback.resp.Record.Value, entry.Value) || !bytes.Equal(callback.resp.Record.Tag, entry.Tag) {
+		t.Fatal("Nonblocking Get with ResponseCallback Failure", err, callback.resp)
+	}
+
+	callback = &recordingCallback{}
+	h = NewResponseCallbackHandler(callback)
+	if err = conn.GetMeta(entry.Key, h); err == nil {
+		err = conn.Listen(h)
+	}
+	if err != nil || !callback.success || !callback.resp.Record.MetaOnly || callback.resp.Record.Value != nil {
+		t.Fatal("Nonblocking GetMeta with ResponseCallback expect metadata only Record", err, callback.resp)
+	}
+
+	callback = &recordingCallback{}
+	h = NewResponseCallbackHandler(callback)
+	if err = conn.GetKeyRange(&KeyRange{StartKey: entry.Key, EndKey: entry.Key, StartKeyInclusive: true,
+		EndKeyInclusive: true, Max: 10}, h); err == nil {
+		err = conn.Listen(h)
+	}
+	if err != nil || !callback.success || len(callback.resp.Keys) != 1 {
+		t.Fatal("Nonblocking GetKeyRange with ResponseCallback Failure", err, callback.resp)
+	}
+
+	callback = &recordingCallback{}
+	h = NewResponseCallbackHandler(callback)
+	if err = conn.GetLog([]LogType{LogTypeLimits}, h); err == nil {
+		err = conn.Listen(h)
+	}
+	if err != nil || !callback.success || callback.resp.Log == nil || callback.resp.Log.Limits == nil {
+		t.Fatal("Nonblocking GetLog with ResponseCallback Failure", err, callback.resp)
+	}
+
+	callback = &recordingCallback{}
+	h = NewResponseCallbackHandler(callback)
+	if err = conn.Get([]byte("callback-not-exist"), h); err == nil {
+		err = conn.Listen(h)
+	}
+	if err != nil || callback.success || callback.resp.Status.Code != RemoteNotFound {
+		t.Fatal("Nonblocking Get with ResponseCallback expect RemoteNotFound", err, callback.resp)
+	}
+}
+
+// recordingLogger records log entries, for test.
+type recordingLogger struct {
+	mu      sync.Mutex
+	level   LogLevel
+	entries []recordedEntry
+}
+
+type recordedEntry struct {
+	level  LogLevel
+	msg    string
+	fields Fields
+}
+
+func (l *recordingLogger) Enabled(level LogLevel) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return level <= l.level
+}
+
+func (l *recordingLogger) Log(level LogLevel, msg string, fields Fields) {
+	l.mu.Lock()
+	l.entries = append(l.entries, recordedEntry{level: level, msg: msg, fields: fields})
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) find(msg string) (recordedEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.msg == msg {
+			return e, true
+		}
+	}
+	return recordedEntry{}, false
+}
+
+func TestNonBlockLogger(t *testing.T) {
+	// Fake kinetic device, never responds.
+	op := startFakeDevice(t, func(c net.Conn) {
+		for {
+			if _, err := readRequest(c); err != nil {
+				return
+			}
+		}
+	})
+	logger := &recordingLogger{level: LogLevelDebug}
+	op.Logger = logger
+
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	callback := &GenericCallback{}
+	h := NewResponseHandler(callback)
+	conn.NoOp(h, WithRequestTimeout(100*time.Millisecond))
+	if err := conn.Listen(h); !errors.Is(err, ClientRequestTimeout) {
+		t.Fatal("Nonblocking NoOp expect ClientRequestTimeout", err)
+	}
+
+	e, ok := logger.find("Request timeout")
+	if !ok {
+		t.Fatal("Request timeout not logged to connection Logger")
+	}
+	if e.fields[FieldHost] != op.Host || e.fields[FieldConnectionID] != int64(1) {
+		t.Fatal("Log entry without host and connection ID", e.fields)
+	}
+	if e.fields[FieldSequence] != h.seq || e.fields[FieldMessageType] != MessageNoop.String() {
+		t.Fatal("Log entry without sequence and message type", e.fields)
+	}
+
+	// Entries below Logger level are not logged
+	logger.mu.Lock()
+	logger.level = LogLevelInfo
+	logger.mu.Unlock()
+	h = NewResponseHandler(&GenericCallback{})
+	conn.NoOp(h, WithRequestTimeout(10*time.Millisecond))
+	conn.Listen(h)
+	logger.mu.Lock()
+	for _, e := range logger.entries {
+		if e.level == LogLevelDebug && e.fields[FieldSequence] == h.seq {
+			t.Error("Debug entry logged at info level", e.msg)
+		}
+	}
+	logger.mu.Unlock()
+}
+
+// startFaultProxy starts fault injecting proxy to kinetic device, returns proxy and options to connect through it.
+func startFaultProxy(t *testing.T) (*faultproxy.Proxy, ClientOptions) {
+	proxy, err := faultproxy.Start(fmt.Sprintf("%s:%d", option.Host, option.Port))
+	if err != nil {
+		t.Fatal("Proxy start Failure", err)
+	}
+	t.Cleanup(func() { proxy.Close() })
+
+	op := option
+	op.Host, op.Port = proxy.Host(), proxy.Port()
+	return proxy, op
+}
+
+func TestBlockNetworkFaults(t *testing.T) {
+	value := make([]byte, 1024)
+	entry := Record{Key: []byte("fault-object"), Value: value, Sync: SyncWriteThrough, Algo: AlgorithmSHA1, Force: true}
+	if status, err := blockConn.Put(&entry); err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	cases := []struct {
+		name  string
+		rule  faultproxy.Rule
+		code  StatusCode
+		alive bool // Connection usable after fault
+	}{
+		{"WrongMagic", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.WrongMagic()}, ClientIOError, false},
+		{"TruncatedHeader", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.Truncate(5)}, ClientIOError, false},
+		{"TruncatedMessage", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.Truncate(20)}, ClientIOError, false},
+		{"DisconnectMidValue", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.DisconnectMidValue()}, ClientIOError, false},
+		{"HMACMismatch", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.CorruptHMAC()}, ClientResponseHMACError, true},
+		{"Delayed", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.Delay(500 * time.Millisecond)}, ClientRequestTimeout, true},
+		{"Dropped", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.Drop()}, ClientRequestTimeout, true},
+		{"StrayUnsolicitedStatus", faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE),
+			Fault: faultproxy.UnsolicitedStatus(kproto.Command_Status_SERVICE_BUSY, "stray")}, OK, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			proxy, op := startFaultProxy(t)
+			conn, err := NewBlockConnection(op)
+			if err != nil {
+				t.Fatal("Blocking connection Failure", err)
+			}
+			defer conn.Close()
+
+			proxy.Inject(c.rule)
+			_, status, _ := conn.Get(entry.Key, WithRequestTimeout(200*time.Millisecond))
+			if status.Code != c.code {
+				t.Fatalf("Blocking Get expect %s, got %s", c.code.String(), status.String())
+			}
+
+			status, err = conn.NoOp()
+			if alive := err == nil && status.Code == OK; alive != c.alive {
+				t.Fatal("Blocking NoOp after fault, expect connection usable", c.alive, err, status.String())
+			}
+		})
+	}
+}
+
+func TestNonBlockHMACMismatch_inFlight(t *testing.T) {
+	proxy, op := startFaultProxy(t)
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// GET request is held, so requests sent after it are in flight when its corrupted response arrives
+	proxy.Inject(faultproxy.Rule{Match: faultproxy.Request(kproto.Command_GET), Fault: faultproxy.Delay(100 * time.Millisecond)})
+	proxy.Inject(faultproxy.Rule{Match: faultproxy.Response(kproto.Command_GET_RESPONSE), Fault: faultproxy.CorruptHMAC()})
+
+	getCallback := &GetCallback{}
+	get := NewResponseHandler(getCallback)
+	conn.Get([]byte("object000"), get)
+	callbacks := make([]*GenericCallback, 20)
+	handlers := make([]*ResponseHandler, len(callbacks))
+	for k := range callbacks {
+		callbacks[k] = &GenericCallback{}
+		handlers[k] = NewResponseHandler(callbacks[k])
+		conn.NoOp(handlers[k])
+	}
+
+	// Only the request of corrupted response fails
+	if err := conn.Listen(get); !errors.Is(err, ClientResponseHMACError) || getCallback.Status().Code != ClientResponseHMACError {
+		t.Fatal("Nonblocking Get expect ClientResponseHMACError", err, getCallback.Status().String())
+	}
+	for k, h := range handlers {
+		if err := conn.Listen(h); err != nil || callbacks[k].Status().Code != OK {
+			t.Fatal("Nonblocking NoOp in flight expect OK", k, err, callbacks[k].Status().String())
+		}
+	}
+}
+
+func TestNonBlockReorderedResponses(t *testing.T) {
+	proxy, op := startFaultProxy(t)
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// First response is held back until the second response sent
+	proxy.Inject(faultproxy.Rule{Match: faultproxy.Response(kproto.Command_NOOP_RESPONSE), Fault: faultproxy.Reorder()})
+
+	callbacks := []*GenericCallback{{}, {}}
+	handlers := []*ResponseHandler{NewResponseHandler(callbacks[0]), NewResponseHandler(callbacks[1])}
+	conn.NoOp(handlers[0])
+	conn.NoOp(handlers[1])
+	for k := range handlers {
+		if err := conn.Listen(handlers[k]); err != nil || callbacks[k].Status().Code != OK {
+			t.Fatal("Nonblocking NoOp with reordered response Failure", k, err, callbacks[k].Status().String())
+		}
+	}
+}
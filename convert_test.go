@@ -0,0 +1,106 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic_test
+
+import (
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/internal/convert"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// Server and simulator convert with internal/convert, which must agree with the client for every protocol value.
+func TestConvertMatchesInternal(t *testing.T) {
+	for v := range kproto.Command_Status_StatusCode_name {
+		p := kproto.Command_Status_StatusCode(v)
+		if a, b := kinetic.ConvertStatusCodeFromProto(p), convert.StatusCodeFromProto(p); a != b {
+			t.Error("StatusCode from protocol mismatch", p, a, b)
+		}
+	}
+	for c := kinetic.RemoteNotAttempted; c <= kinetic.ClientRequestTimeout; c++ {
+		if a, b := kinetic.ConvertStatusCodeToProto(c), convert.StatusCodeToProto(c); a != b {
+			t.Error("StatusCode to protocol mismatch", c, a, b)
+		}
+	}
+
+	for v := range kproto.Command_MessageType_name {
+		p := kproto.Command_MessageType(v)
+		m := kinetic.ConvertMessageTypeFromProto(p)
+		if b := convert.MessageTypeFromProto(p); m != b {
+			t.Error("MessageType from protocol mismatch", p, m, b)
+		}
+		if a, b := kinetic.ConvertMessageTypeToProto(m), convert.MessageTypeToProto(m); a != b {
+			t.Error("MessageType to protocol mismatch", m, a, b)
+		}
+	}
+
+	for v := range kproto.Command_GetLog_Type_name {
+		p := kproto.Command_GetLog_Type(v)
+		if a, b := kinetic.ConvertLogTypeFromProto(p), convert.LogTypeFromProto(p); a != b {
+			t.Error("LogType from protocol mismatch", p, a, b)
+		}
+	}
+
+	for v := range kproto.Command_Algorithm_name {
+		p := kproto.Command_Algorithm(v)
+		algo := kinetic.ConvertAlgoFromProto(p)
+		if b := convert.RecordFromProto(&kproto.Command_KeyValue{Algorithm: p.Enum()}, nil).Algo; algo != b {
+			t.Error("Algorithm from protocol mismatch", p, algo, b)
+		}
+		if a, b := kinetic.ConvertAlgoToProto(algo), convert.RecordToProto(&kinetic.Record{Algo: algo}).GetAlgorithm(); a != b {
+			t.Error("Algorithm to protocol mismatch", algo, a, b)
+		}
+	}
+
+	for v := range kproto.Command_Synchronization_name {
+		p := kproto.Command_Synchronization(v)
+		if a, b := kinetic.ConvertSyncFromProto(p), convert.RecordFromProto(&kproto.Command_KeyValue{Synchronization: p.Enum()}, nil).Sync; a != b {
+			t.Error("Synchronization from protocol mismatch", p, a, b)
+		}
+	}
+
+	for v := range kproto.Command_Priority_name {
+		p := kproto.Command_Priority(v)
+		if a, b := kinetic.ConvertPriorityFromProto(p), convert.ACLFromProto(&kproto.Command_Security_ACL{MaxPriority: p.Enum()}).MaxPriority; a != b {
+			t.Error("Priority from protocol mismatch", p, a, b)
+		}
+	}
+
+	for v := range kproto.Command_Security_ACL_Permission_name {
+		p := kproto.Command_Security_ACL_Permission(v)
+		acl := convert.ACLFromProto(&kproto.Command_Security_ACL{Scope: []*kproto.Command_Security_ACL_Scope{
+			{Permission: []kproto.Command_Security_ACL_Permission{p}},
+		}})
+		if a, b := kinetic.ConvertACLPermissionFromProto(p), acl.Scopes[0].Permissions[0]; a != b {
+			t.Error("ACLPermission from protocol mismatch", p, a, b)
+		}
+	}
+
+	for v := range kproto.Command_PowerLevel_name {
+		p := kproto.Command_PowerLevel(v)
+		level := kinetic.ConvertPowerLevelFromProto(p)
+		if b := convert.PowerLevelFromProto(p); level != b {
+			t.Error("PowerLevel from protocol mismatch", p, level, b)
+		}
+		log := convert.LogToProto(&kinetic.Log{Configuration: &kinetic.ConfigurationLog{CurrentPowerLevel: level}})
+		if a, b := kinetic.ConvertPowerLevelToProto(level), log.GetConfiguration().GetCurrentPowerLevel(); a != b {
+			t.Error("PowerLevel to protocol mismatch", level, a, b)
+		}
+	}
+}
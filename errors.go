@@ -0,0 +1,94 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is the error of failed kinetic request, it wraps the Status of the request.
+// Status gives the detailed message from kinetic device, message type and sequence of the failed command.
+// Use errors.Is(err, RemoteNotFound) to match StatusCode, and errors.As(err, &kerr) to get *Error.
+type Error struct {
+	Status Status
+}
+
+// Error returns the status code, message type and sequence of the failed request.
+func (e *Error) Error() string {
+	return fmt.Sprintf("kinetic %s request sequence %d failed, %s", e.Status.MessageType.String(), e.Status.Sequence, e.Status.String())
+}
+
+// Unwrap returns the wrapped Status.
+func (e *Error) Unwrap() error {
+	return e.Status
+}
+
+// statusCode returns the StatusCode of err, from Status or StatusCode in its chain.
+func statusCode(err error) (StatusCode, bool) {
+	var s Status
+	if errors.As(err, &s) {
+		return s.Code, true
+	}
+	var c StatusCode
+	if errors.As(err, &c) {
+		return c, true
+	}
+	return OK, false
+}
+
+// IsRetryable returns true if err is a transient failure in DefaultRetryableCodes, the request may succeed
+// if retried.
+func IsRetryable(err error) bool {
+	code, ok := statusCode(err)
+	return ok && containsStatusCode(DefaultRetryableCodes, code)
+}
+
+// IsAuthorization returns true if err is caused by identity, HMAC key or permission of the request.
+func IsAuthorization(err error) bool {
+	code, ok := statusCode(err)
+	if !ok {
+		return false
+	}
+	switch code {
+	case RemoteHMACError, RemoteNotAuthorized, RemoteNoSuchHMACAlgorithm:
+		return true
+	}
+	return false
+}
+
+// IsNotFound returns true if err is caused by object not found on kinetic device.
+func IsNotFound(err error) bool {
+	code, ok := statusCode(err)
+	return ok && code == RemoteNotFound
+}
+
+// IsDeviceState returns true if err is caused by kinetic device state, eg. device locked, out of space,
+// hibernate or shutdown, or cluster version mismatch.
+func IsDeviceState(err error) bool {
+	code, ok := statusCode(err)
+	if !ok {
+		return false
+	}
+	switch code {
+	case RemoteDeviceLocked, RemoteDeviceAlreadyUnlocked, RemoteNoSpace, RemoteClusterVersionMismatch,
+		RemoteConnectionTerminated, RemoteHibernate, RemoteShutdown:
+		return true
+	}
+	return false
+}
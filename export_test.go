@@ -0,0 +1,42 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import "testing"
+
+// RunTests runs tests against kinetic device on host, for TestMain in package kinetic_test.
+func RunTests(m *testing.M, host string, port, tls int) int {
+	option.Host, option.Port, tlsPort = host, port, tls
+	return runTests(m)
+}
+
+// Protocol converters, for test against internal/convert used by server and simulator.
+var (
+	ConvertStatusCodeToProto      = convertStatusCodeToProto
+	ConvertStatusCodeFromProto    = convertStatusCodeFromProto
+	ConvertMessageTypeToProto     = convertMessageTypeToProto
+	ConvertMessageTypeFromProto   = convertMessageTypeFromProto
+	ConvertLogTypeFromProto       = convertLogTypeFromProto
+	ConvertAlgoToProto            = convertAlgoToProto
+	ConvertAlgoFromProto          = convertAlgoFromProto
+	ConvertSyncFromProto          = convertSyncFromProto
+	ConvertPriorityFromProto      = convertPriorityFromProto
+	ConvertACLPermissionFromProto = convertACLPermissionFromProto
+	ConvertPowerLevelToProto      = convertPowerLevelToProto
+	ConvertPowerLevelFromProto    = convertPowerLevelFromProto
+)
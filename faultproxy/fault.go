@@ -0,0 +1,126 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package faultproxy
+
+import (
+	"time"
+
+	"github.com/Kinetic/kinetic-go/codec"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Fault is injected to the frame matched by Rule, instead of forwarding it as is.
+type Fault struct {
+	apply func(s *stream, f *Frame) error
+}
+
+// Drop discards the frame.
+func Drop() Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		return nil
+	}}
+}
+
+// Delay forwards the frame after d. Following frames in the same direction are delayed as well.
+func Delay(d time.Duration) Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		time.Sleep(d)
+		return s.forward(f.raw)
+	}}
+}
+
+// Reorder holds the frame back, and forwards it after the next frame in the same direction.
+func Reorder() Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		s.held = append(s.held, f.raw)
+		return nil
+	}}
+}
+
+// WrongMagic forwards the frame with wrong magic byte in header.
+func WrongMagic() Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		raw := append([]byte(nil), f.raw...)
+		raw[0] = 'X'
+		return s.forward(raw)
+	}}
+}
+
+// Truncate forwards only the first n bytes of the frame, then closes the connection.
+func Truncate(n int) Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		s.write(f.raw[:min(n, len(f.raw))])
+		return errFault
+	}}
+}
+
+// DisconnectMidValue forwards the frame up to half of its value, then closes the connection.
+// Frame without value is truncated in the middle of message.
+func DisconnectMidValue() Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		n := len(f.raw) - len(f.Value)/2
+		if len(f.Value) == 0 {
+			n = codec.HeaderSize + (len(f.raw)-codec.HeaderSize)/2
+		}
+		s.write(f.raw[:n])
+		return errFault
+	}}
+}
+
+// Disconnect closes the connection instead of forwarding the frame.
+func Disconnect() Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		return errFault
+	}}
+}
+
+// CorruptHMAC forwards the frame with HMAC changed, so HMAC verification fails.
+// Frame without HMAC is forwarded as is.
+func CorruptHMAC() Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		if f.Message.GetHmacAuth() == nil || len(f.Message.GetHmacAuth().GetHmac()) == 0 {
+			return s.forward(f.raw)
+		}
+		msg := proto.Clone(f.Message).(*kproto.Message)
+		msg.GetHmacAuth().Hmac[0] ^= 0xff
+		return s.forward(encodeFrame(msg, nil, f.Value))
+	}}
+}
+
+// UnsolicitedStatus sends UNSOLICITEDSTATUS message with code and msg before forwarding the frame.
+func UnsolicitedStatus(code kproto.Command_Status_StatusCode, msg string) Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		if err := s.write(unsolicitedStatus(code, msg)); err != nil {
+			return err
+		}
+		return s.forward(f.raw)
+	}}
+}
+
+// Replace forwards the frame modified by fn. fn may change Message, Command and Value,
+// Command is encoded into Message.CommandBytes before forwarding. HMAC is not recomputed.
+func Replace(fn func(f *Frame)) Fault {
+	return Fault{apply: func(s *stream, f *Frame) error {
+		if f.Message == nil {
+			return s.forward(f.raw)
+		}
+		fn(f)
+		return s.forward(encodeFrame(f.Message, f.Command, f.Value))
+	}}
+}
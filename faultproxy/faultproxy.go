@@ -0,0 +1,390 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+/*
+Package faultproxy is a TCP proxy between kinetic client and kinetic device, which injects
+scripted faults into the framed protocol for resilience testing.
+
+	proxy, err := faultproxy.Start("127.0.0.1:8123")
+	if err != nil {
+		panic(err)
+	}
+	defer proxy.Close()
+
+	// Corrupt HMAC of the first GET response
+	proxy.Inject(faultproxy.Rule{
+		Match: faultproxy.Response(kproto.Command_GET_RESPONSE),
+		Fault: faultproxy.CorruptHMAC(),
+	})
+
+	option.Host, option.Port = proxy.Host(), proxy.Port()
+
+Proxy listens on loopback only.
+*/
+package faultproxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/Kinetic/kinetic-go/codec"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Direction of frame through proxy.
+type Direction int
+
+const (
+	// ToDevice frames are requests from client to device.
+	ToDevice Direction = iota
+	// ToClient frames are responses and unsolicited status from device to client.
+	ToClient
+)
+
+// Frame is one kinetic protocol message passing through proxy.
+// Message and Command are nil if the frame can't be parsed.
+type Frame struct {
+	Direction Direction
+	Message   *kproto.Message
+	Command   *kproto.Command
+	Value     []byte
+	raw       []byte // Frame as received, header, message and value
+}
+
+// MessageType returns message type of the frame, INVALID_MESSAGE_TYPE if frame has no header.
+func (f *Frame) MessageType() kproto.Command_MessageType {
+	if f.Command.GetHeader() == nil {
+		return kproto.Command_INVALID_MESSAGE_TYPE
+	}
+	return f.Command.GetHeader().GetMessageType()
+}
+
+// Sequence returns sequence of request, or acknowledged sequence of response. -1 if not available.
+func (f *Frame) Sequence() int64 {
+	h := f.Command.GetHeader()
+	switch {
+	case f.Direction == ToDevice && h != nil && h.Sequence != nil:
+		return h.GetSequence()
+	case f.Direction == ToClient && h != nil && h.AckSequence != nil:
+		return h.GetAckSequence()
+	}
+	return -1
+}
+
+// Matcher selects frames a fault is injected to.
+type Matcher func(f *Frame) bool
+
+// Request matches requests of message type t.
+func Request(t kproto.Command_MessageType) Matcher {
+	return func(f *Frame) bool {
+		return f.Direction == ToDevice && f.MessageType() == t
+	}
+}
+
+// Response matches responses of message type t.
+func Response(t kproto.Command_MessageType) Matcher {
+	return func(f *Frame) bool {
+		return f.Direction == ToClient && f.MessageType() == t
+	}
+}
+
+// Sequence matches request with sequence seq and its response.
+func Sequence(seq int64) Matcher {
+	return func(f *Frame) bool {
+		return f.Sequence() == seq
+	}
+}
+
+// Handshake matches the handshake message sent by device when connection established.
+func Handshake() Matcher {
+	return func(f *Frame) bool {
+		return f.Direction == ToClient && f.Message.GetAuthType() == kproto.Message_UNSOLICITEDSTATUS &&
+			f.Command.GetHeader().GetConnectionID() != 0
+	}
+}
+
+// All matches frames matched by all matchers.
+func All(matchers ...Matcher) Matcher {
+	return func(f *Frame) bool {
+		for _, m := range matchers {
+			if !m(f) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Rule injects Fault to frames selected by Match, nil Match selects all frames.
+type Rule struct {
+	Match Matcher
+	Fault Fault
+	Count int // Number of frames fault is injected to, 0 for the first matching frame only, -1 for all
+}
+
+// Proxy forwards connections from client to target, and injects faults by rules.
+type Proxy struct {
+	mu      sync.Mutex
+	target  string
+	ln      net.Listener
+	rules   []*Rule
+	streams map[*stream]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// Start starts proxy on loopback ephemeral port, forwarding connections to target host:port.
+func Start(target string) (*Proxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{target: target, ln: ln, streams: make(map[*stream]struct{})}
+	p.wg.Add(1)
+	go p.accept()
+	return p, nil
+}
+
+// Host returns the address proxy listens on.
+func (p *Proxy) Host() string {
+	return p.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port proxy listens on.
+func (p *Proxy) Port() int {
+	return p.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Addr returns host:port proxy listens on.
+func (p *Proxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// Inject adds rule, it applies to frames forwarded afterwards, on all connections.
+// Rules are checked in the order injected, only the first matching rule applies to a frame.
+func (p *Proxy) Inject(r Rule) {
+	p.mu.Lock()
+	p.rules = append(p.rules, &r)
+	p.mu.Unlock()
+}
+
+// Reset removes all rules not applied yet.
+func (p *Proxy) Reset() {
+	p.mu.Lock()
+	p.rules = nil
+	p.mu.Unlock()
+}
+
+// SendUnsolicitedStatus sends UNSOLICITEDSTATUS message with code and msg to all connected clients.
+func (p *Proxy) SendUnsolicitedStatus(code kproto.Command_Status_StatusCode, msg string) {
+	p.mu.Lock()
+	streams := make([]*stream, 0, len(p.streams))
+	for s := range p.streams {
+		if s.dir == ToClient {
+			streams = append(streams, s)
+		}
+	}
+	p.mu.Unlock()
+
+	raw := unsolicitedStatus(code, msg)
+	for _, s := range streams {
+		s.write(raw)
+	}
+}
+
+// Disconnect closes all connections through proxy.
+func (p *Proxy) Disconnect() {
+	p.mu.Lock()
+	for s := range p.streams {
+		s.close()
+	}
+	p.mu.Unlock()
+}
+
+// Close stops proxy and closes all connections.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.ln.Close()
+	for s := range p.streams {
+		s.close()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
+
+func (p *Proxy) accept() {
+	defer p.wg.Done()
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		device, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+
+		toDevice := &stream{proxy: p, dir: ToDevice, src: client, dst: device}
+		toClient := &stream{proxy: p, dir: ToClient, src: device, dst: client}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			client.Close()
+			device.Close()
+			return
+		}
+		p.streams[toDevice] = struct{}{}
+		p.streams[toClient] = struct{}{}
+		p.mu.Unlock()
+
+		p.wg.Add(2)
+		go toDevice.pump()
+		go toClient.pump()
+	}
+}
+
+// rule returns fault of the first rule matching f, and consumes the rule.
+func (p *Proxy) rule(f *Frame) *Fault {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, r := range p.rules {
+		if r.Match != nil && !r.Match(f) {
+			continue
+		}
+		switch {
+		case r.Count == 0 || r.Count == 1:
+			p.rules = append(p.rules[:k:k], p.rules[k+1:]...)
+		case r.Count > 1:
+			r.Count--
+		}
+		return &r.Fault
+	}
+	return nil
+}
+
+// stream forwards frames in one direction of a proxied connection.
+type stream struct {
+	proxy *Proxy
+	dir   Direction
+	src   net.Conn
+	dst   net.Conn
+	mu    sync.Mutex // Serializes writes to dst
+	held  [][]byte   // Frames held back by Reorder, sent after next frame
+}
+
+func (s *stream) pump() {
+	defer s.proxy.wg.Done()
+	defer func() {
+		s.proxy.mu.Lock()
+		delete(s.proxy.streams, s)
+		s.proxy.mu.Unlock()
+		s.close()
+	}()
+
+	for {
+		f, err := readFrame(s.src, s.dir)
+		if err != nil {
+			return
+		}
+		if fault := s.proxy.rule(f); fault != nil && fault.apply != nil {
+			if err := fault.apply(s, f); err != nil {
+				return
+			}
+			continue
+		}
+		if err := s.forward(f.raw); err != nil {
+			return
+		}
+	}
+}
+
+// forward writes frame to dst, followed by frames held back.
+func (s *stream) forward(raw []byte) error {
+	if err := s.write(raw); err != nil {
+		return err
+	}
+	held := s.held
+	s.held = nil
+	for _, h := range held {
+		if err := s.write(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *stream) write(raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.dst.Write(raw)
+	return err
+}
+
+// close closes both sides of the proxied connection.
+func (s *stream) close() {
+	s.src.Close()
+	s.dst.Close()
+}
+
+// errFault is returned by fault which terminates the connection.
+var errFault = errors.New("Connection terminated by fault")
+
+func readFrame(r io.Reader, dir Direction) (*Frame, error) {
+	var raw bytes.Buffer
+	rd := codec.NewReader(io.TeeReader(r, &raw))
+	h, err := rd.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+	f, err := rd.ReadBody(h)
+	if err == nil {
+		return &Frame{Direction: dir, Message: f.Message, Command: f.Command, Value: f.Value, raw: raw.Bytes()}, nil
+	}
+
+	// Frame is forwarded as received, even if it can't be parsed.
+	size := codec.HeaderSize + int(h.MessageSize) + int(h.ValueSize)
+	if raw.Len() != size {
+		return nil, err
+	}
+	return &Frame{Direction: dir, Value: raw.Bytes()[size-int(h.ValueSize):], raw: raw.Bytes()}, nil
+}
+
+// encodeFrame encodes message, command and value as kinetic protocol frame.
+// Command is encoded into message if not nil, HMAC is kept as is.
+func encodeFrame(msg *kproto.Message, cmd *kproto.Command, value []byte) []byte {
+	raw, _ := codec.NewWriter(nil).Encode(&codec.Frame{Message: msg, Command: cmd, Value: value})
+	return raw
+}
+
+func unsolicitedStatus(code kproto.Command_Status_StatusCode, msg string) []byte {
+	return encodeFrame(&kproto.Message{AuthType: kproto.Message_UNSOLICITEDSTATUS.Enum()},
+		&kproto.Command{
+			Status: &kproto.Command_Status{Code: code.Enum(), StatusMessage: proto.String(msg)},
+		}, nil)
+}
@@ -0,0 +1,62 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package faultproxy_test
+
+import (
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/faultproxy"
+	"github.com/Kinetic/kinetic-go/kinetictest"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+func TestProxyRuleCount(t *testing.T) {
+	drive := kinetictest.NewDrive(t)
+	proxy, err := faultproxy.Start(drive.Addr())
+	if err != nil {
+		t.Fatal("Proxy start Failure", err)
+	}
+	defer proxy.Close()
+
+	op := drive.Options()
+	op.Host, op.Port = proxy.Host(), proxy.Port()
+	conn, err := kinetic.NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection through proxy Failure", err)
+	}
+	defer conn.Close()
+
+	// Corrupt HMAC of the next two NOOP responses only
+	proxy.Inject(faultproxy.Rule{
+		Match: faultproxy.Response(kproto.Command_NOOP_RESPONSE),
+		Fault: faultproxy.CorruptHMAC(),
+		Count: 2,
+	})
+	for k, code := range []kinetic.StatusCode{kinetic.ClientResponseHMACError, kinetic.ClientResponseHMACError, kinetic.OK} {
+		if status, _ := conn.NoOp(); status.Code != code {
+			t.Fatalf("NoOp %d expect %s, got %s", k, code.String(), status.String())
+		}
+	}
+
+	// Disconnect applies to all connections through proxy
+	proxy.Disconnect()
+	if status, _ := conn.NoOp(); status.Code == kinetic.OK {
+		t.Fatal("NoOp after proxy disconnect expect failure")
+	}
+}
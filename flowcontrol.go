@@ -0,0 +1,100 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"context"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// FlowControl specifies how requests are admitted when outstanding requests reach kinetic device
+// limits MaxOutstandingReadRequests or MaxOutstandingWriteRequests, reported by LimitsLog in handshake.
+// Read and write requests are limited separately.
+type FlowControl int
+
+const (
+	// FlowControlBlock blocks the request until an outstanding request of same class completes.
+	FlowControlBlock FlowControl = iota
+	// FlowControlFailFast fails the request immediately with LimitError and ClientLimitExceeded.
+	FlowControlFailFast
+	// FlowControlNone sends all requests without limit, device may respond RemoteServiceBusy.
+	FlowControlNone
+)
+
+// admission tracks outstanding read and write requests against kinetic device limits.
+type admission struct {
+	mode     FlowControl
+	read     chan struct{} // Slots of outstanding read requests, nil for no limit
+	write    chan struct{} // Slots of outstanding write requests, nil for no limit
+	maxRead  uint32
+	maxWrite uint32
+}
+
+func newAdmission(mode FlowControl, limits *LimitsLog) *admission {
+	a := &admission{mode: mode}
+	if mode == FlowControlNone || limits == nil {
+		return a
+	}
+	if limits.MaxOutstandingReadRequests > 0 {
+		a.maxRead = limits.MaxOutstandingReadRequests
+		a.read = make(chan struct{}, a.maxRead)
+	}
+	if limits.MaxOutstandingWriteRequests > 0 {
+		a.maxWrite = limits.MaxOutstandingWriteRequests
+		a.write = make(chan struct{}, a.maxWrite)
+	}
+	return a
+}
+
+// acquire takes a slot for request of message type t, read requests and write requests are counted separately.
+// Returns the function to release the slot when request completes.
+// With FlowControlBlock, acquire waits until a slot is released, ctx is done or closing is closed.
+func (a *admission) acquire(ctx context.Context, t kproto.Command_MessageType, closing <-chan struct{}) (func(), error) {
+	slots, limit, max := a.write, "MaxOutstandingWriteRequests", a.maxWrite
+	if isIdempotent(t) {
+		slots, limit, max = a.read, "MaxOutstandingReadRequests", a.maxRead
+	}
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if a.mode == FlowControlFailFast {
+		return nil, &LimitError{Limit: limit, Max: max, Size: int(max) + 1}
+	}
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-done:
+		return nil, ctx.Err()
+	case <-closing:
+		return nil, ErrConnectionClosed
+	}
+}
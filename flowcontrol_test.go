@@ -0,0 +1,105 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+func TestAdmissionFailFast(t *testing.T) {
+	a := newAdmission(FlowControlFailFast, &LimitsLog{MaxOutstandingReadRequests: 1, MaxOutstandingWriteRequests: 1})
+	closing := make(chan struct{})
+
+	release, err := a.acquire(nil, kproto.Command_GET, closing)
+	if err != nil {
+		t.Fatal("First read request should be admitted", err)
+	}
+	_, err = a.acquire(nil, kproto.Command_GETNEXT, closing)
+	if e, ok := err.(*LimitError); !ok || e.Limit != "MaxOutstandingReadRequests" {
+		t.Fatal("Second read request expect LimitError", err)
+	}
+
+	// Write requests are limited separately from read requests.
+	wrelease, err := a.acquire(nil, kproto.Command_PUT, closing)
+	if err != nil {
+		t.Fatal("Write request should be admitted", err)
+	}
+	wrelease()
+
+	release()
+	release, err = a.acquire(nil, kproto.Command_GET, closing)
+	if err != nil {
+		t.Fatal("Read request should be admitted after slot released", err)
+	}
+	release()
+}
+
+func TestAdmissionBlock(t *testing.T) {
+	a := newAdmission(FlowControlBlock, &LimitsLog{MaxOutstandingReadRequests: 1, MaxOutstandingWriteRequests: 1})
+	closing := make(chan struct{})
+
+	release, err := a.acquire(nil, kproto.Command_PUT, closing)
+	if err != nil {
+		t.Fatal("First write request should be admitted", err)
+	}
+
+	admitted := make(chan error)
+	go func() {
+		r, err := a.acquire(nil, kproto.Command_DELETE, closing)
+		if err == nil {
+			r()
+		}
+		admitted <- err
+	}()
+
+	select {
+	case err := <-admitted:
+		t.Fatal("Second write request should be blocked", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	if err := <-admitted; err != nil {
+		t.Fatal("Second write request should be admitted after slot released", err)
+	}
+
+	// Blocked request returns when context canceled or connection closed.
+	release, _ = a.acquire(nil, kproto.Command_PUT, closing)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.acquire(ctx, kproto.Command_PUT, closing); err != context.DeadlineExceeded {
+		t.Fatal("Blocked write request expect context deadline exceeded", err)
+	}
+	close(closing)
+	if _, err := a.acquire(nil, kproto.Command_PUT, closing); err != ErrConnectionClosed {
+		t.Fatal("Blocked write request expect connection closed", err)
+	}
+	release()
+}
+
+func TestAdmissionNone(t *testing.T) {
+	a := newAdmission(FlowControlNone, &LimitsLog{MaxOutstandingReadRequests: 1, MaxOutstandingWriteRequests: 1})
+	for i := 0; i < 3; i++ {
+		if _, err := a.acquire(nil, kproto.Command_GET, nil); err != nil {
+			t.Fatal("FlowControlNone should admit all requests", err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"context"
+	"sync"
+)
+
+// Future is the pending result of request sent by NonBlockConnection. Result is available after
+// response message received from kinetic device, or request failed.
+// Wait can be called concurrently, all callers get the same result.
+type Future[T any] struct {
+	h        *ResponseHandler
+	callback Callback
+	result   func() (T, error)
+	failure  func(s Status) (T, error) // Result for response Status not OK, nil for Status.Err
+	once     sync.Once                 // Result resolved once, after response handled
+	value    T
+	err      error
+}
+
+// async submits request by submit, with new ResponseHandler for callback. result is called to
+// get the result after response message received with OK status.
+func async[T any](callback Callback, submit func(h *ResponseHandler) error, result func() (T, error)) *Future[T] {
+	h := NewResponseHandler(callback)
+	if err := submit(h); err != nil && !h.isDone() {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err)
+	}
+	return &Future[T]{h: h, callback: callback, result: result}
+}
+
+// Done returns a channel closed when response message received, or request failed.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.h.finished
+}
+
+// Wait waits for the result until ctx is done. If response status is not OK, error is *Error wraps the Status.
+// If ctx is done first, ctx.Err() is returned and the request is not canceled, use WithContext to cancel request.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.h.finished:
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+
+	f.once.Do(f.resolve)
+	return f.value, f.err
+}
+
+// resolve gets result of request from callback, after response handled.
+func (f *Future[T]) resolve() {
+	if err := f.h.wait(); err != nil {
+		f.err = err
+		return
+	}
+	if status := f.callback.Status(); status.Code != OK {
+		if f.failure != nil {
+			f.value, f.err = f.failure(status)
+		} else {
+			f.err = status.Err()
+		}
+		return
+	}
+	f.value, f.err = f.result()
+}
+
+// Status returns the Status of request, after Done is closed.
+func (f *Future[T]) Status() Status {
+	<-f.h.finished
+	return f.callback.Status()
+}
+
+func (conn *NonBlockConnection) getAsync(key []byte, submit func(key []byte, h *ResponseHandler, opts ...RequestOption) error,
+	metaOnly bool, opts []RequestOption) *Future[*Record] {
+	callback := &GetCallback{}
+	return async(callback, func(h *ResponseHandler) error {
+		return submit(key, h, opts...)
+	}, func() (*Record, error) {
+		if metaOnly {
+			callback.Entry.MetaOnly = true
+			callback.Entry.Value = nil
+		}
+		return &callback.Entry, nil
+	})
+}
+
+// statusAsync submits request which doesn't require data from kinetic device, result of Future is the Status.
+func statusAsync(submit func(h *ResponseHandler) error) *Future[Status] {
+	callback := &GenericCallback{}
+	return async(callback, submit, func() (Status, error) {
+		return callback.Status(), nil
+	})
+}
+
+// NoOpAsync does nothing but wait for drive to return response.
+func (conn *NonBlockConnection) NoOpAsync(opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.NoOp(h, opts...)
+	})
+}
+
+// GetAsync gets the object from kinetic drive with key.
+func (conn *NonBlockConnection) GetAsync(key []byte, opts ...RequestOption) *Future[*Record] {
+	return conn.getAsync(key, conn.Get, false, opts)
+}
+
+// GetNextAsync gets the next object with key after the passed in key.
+func (conn *NonBlockConnection) GetNextAsync(key []byte, opts ...RequestOption) *Future[*Record] {
+	return conn.getAsync(key, conn.GetNext, false, opts)
+}
+
+// GetPreviousAsync gets the previous object with key before the passed in key.
+func (conn *NonBlockConnection) GetPreviousAsync(key []byte, opts ...RequestOption) *Future[*Record] {
+	return conn.getAsync(key, conn.GetPrevious, false, opts)
+}
+
+// GetMetaAsync gets the object metadata from kinetic drive with key, object value is not transferred.
+func (conn *NonBlockConnection) GetMetaAsync(key []byte, opts ...RequestOption) *Future[*Record] {
+	return conn.getAsync(key, conn.GetMeta, true, opts)
+}
+
+// GetNextMetaAsync gets the next object metadata with key after the passed in key, object value is not transferred.
+func (conn *NonBlockConnection) GetNextMetaAsync(key []byte, opts ...RequestOption) *Future[*Record] {
+	return conn.getAsync(key, conn.GetNextMeta, true, opts)
+}
+
+// GetPreviousMetaAsync gets the previous object metadata with key before the passed in key, object value is not transferred.
+func (conn *NonBlockConnection) GetPreviousMetaAsync(key []byte, opts ...RequestOption) *Future[*Record] {
+	return conn.getAsync(key, conn.GetPreviousMeta, true, opts)
+}
+
+// GetKeyRangeAsync gets list of objects' keys, which meet the criteria defined by KeyRange.
+func (conn *NonBlockConnection) GetKeyRangeAsync(r *KeyRange, opts ...RequestOption) *Future[[][]byte] {
+	callback := &GetKeyRangeCallback{}
+	return async(callback, func(h *ResponseHandler) error {
+		return conn.GetKeyRange(r, h, opts...)
+	}, func() ([][]byte, error) {
+		return callback.Keys, nil
+	})
+}
+
+// GetVersionAsync gets object DB version information.
+func (conn *NonBlockConnection) GetVersionAsync(key []byte, opts ...RequestOption) *Future[[]byte] {
+	callback := &GetVersionCallback{}
+	return async(callback, func(h *ResponseHandler) error {
+		return conn.GetVersion(key, h, opts...)
+	}, func() ([]byte, error) {
+		return callback.Version, nil
+	})
+}
+
+// FlushAsync requests kinetic device to write all cached data to persistent media.
+func (conn *NonBlockConnection) FlushAsync(opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.Flush(h, opts...)
+	})
+}
+
+// writeAsync submits PUT or DELETE. If entry.Version doesn't match object version on kinetic device,
+// error is *VersionConflictError.
+func writeAsync(entry *Record, submit func(entry *Record, h *ResponseHandler, opts ...RequestOption) error,
+	opts []RequestOption) *Future[Status] {
+	callback := &WriteCallback{}
+	f := async(callback, func(h *ResponseHandler) error {
+		return submit(entry, h, opts...)
+	}, func() (Status, error) {
+		return callback.Status(), nil
+	})
+	f.failure = func(s Status) (Status, error) {
+		if callback.Conflict == nil {
+			return Status{}, s.Err()
+		}
+		callback.Conflict.Key = entry.Key
+		callback.Conflict.ExpectedVersion = entry.Version
+		return Status{}, callback.Conflict
+	}
+	return f
+}
+
+// DeleteAsync deletes object from kinetic device.
+func (conn *NonBlockConnection) DeleteAsync(entry *Record, opts ...RequestOption) *Future[Status] {
+	return writeAsync(entry, conn.Delete, opts)
+}
+
+// PutAsync store object to kinetic device.
+func (conn *NonBlockConnection) PutAsync(entry *Record, opts ...RequestOption) *Future[Status] {
+	return writeAsync(entry, conn.Put, opts)
+}
+
+// P2PPushAsync performs peer to peer push operation.
+func (conn *NonBlockConnection) P2PPushAsync(request *P2PPushRequest, opts ...RequestOption) *Future[*P2PPushStatus] {
+	callback := &P2PPushCallback{}
+	return async(callback, func(h *ResponseHandler) error {
+		return conn.P2PPush(request, h, opts...)
+	}, func() (*P2PPushStatus, error) {
+		return &callback.P2PStatus, nil
+	})
+}
+
+// BatchStartAsync starts new batch operation, all following batch PUT / DELETE share same batch ID until
+// BatchEnd or BatchAbort is called.
+func (conn *NonBlockConnection) BatchStartAsync(opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.BatchStart(h, opts...)
+	})
+}
+
+// BatchEndAsync commits all batch jobs. If batch fails, BatchStatus with the first failed job
+// sequence number is returned with the error, as BlockConnection.BatchEnd does.
+func (conn *NonBlockConnection) BatchEndAsync(opts ...RequestOption) *Future[*BatchStatus] {
+	callback := &BatchEndCallback{}
+	f := async(callback, func(h *ResponseHandler) error {
+		return conn.BatchEnd(h, opts...)
+	}, func() (*BatchStatus, error) {
+		return &callback.BatchStatus, nil
+	})
+	f.failure = func(s Status) (*BatchStatus, error) {
+		return &callback.BatchStatus, s.Err()
+	}
+	return f
+}
+
+// BatchAbortAsync aborts jobs in current batch operation.
+func (conn *NonBlockConnection) BatchAbortAsync(opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.BatchAbort(h, opts...)
+	})
+}
+
+// GetLogAsync gets kinetic device Log information. Can request single LogType or multiple LogType.
+func (conn *NonBlockConnection) GetLogAsync(logs []LogType, opts ...RequestOption) *Future[*Log] {
+	callback := &GetLogCallback{}
+	return async(callback, func(h *ResponseHandler) error {
+		return conn.GetLog(logs, h, opts...)
+	}, func() (*Log, error) {
+		return &callback.Logs, nil
+	})
+}
+
+// SecureEraseAsync request kinetic device to perform secure erase.
+// SSL connection is requested to perform this operation, and the erase pin is needed.
+func (conn *NonBlockConnection) SecureEraseAsync(pin []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.SecureErase(pin, h, opts...)
+	})
+}
+
+// InstantEraseAsync request kinetic device to perform instant erase.
+// SSL connection is requested to perform this operation, and the erase pin is needed.
+func (conn *NonBlockConnection) InstantEraseAsync(pin []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.InstantErase(pin, h, opts...)
+	})
+}
+
+// LockDeviceAsync locks the kinetic device.
+// SSL connection is requested to perform this operation, and the lock pin is needed.
+func (conn *NonBlockConnection) LockDeviceAsync(pin []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.LockDevice(pin, h, opts...)
+	})
+}
+
+// UnlockDeviceAsync unlocks the kinetic device.
+// SSL connection is requested to perform this operation, and the lock pin is needed.
+func (conn *NonBlockConnection) UnlockDeviceAsync(pin []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.UnlockDevice(pin, h, opts...)
+	})
+}
+
+// UpdateFirmwareAsync requests to update kientic device firmware.
+func (conn *NonBlockConnection) UpdateFirmwareAsync(code []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.UpdateFirmware(code, h, opts...)
+	})
+}
+
+// SetClusterVersionAsync sets the cluster version on kinetic drive.
+func (conn *NonBlockConnection) SetClusterVersionAsync(version int64, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.SetClusterVersion(version, h, opts...)
+	})
+}
+
+// SetLockPinAsync changes kinetic device lock pin. Both current pin and new pin needed.
+// SSL connection is required to perform this operation.
+func (conn *NonBlockConnection) SetLockPinAsync(currentPin []byte, newPin []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.SetLockPin(currentPin, newPin, h, opts...)
+	})
+}
+
+// SetErasePinAsync changes kinetic device erase pin. Both current pin and new pin needed.
+// SSL connection is required to perform this operation.
+func (conn *NonBlockConnection) SetErasePinAsync(currentPin []byte, newPin []byte, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.SetErasePin(currentPin, newPin, h, opts...)
+	})
+}
+
+// SetACLAsync sets Permission for particular user Identity.
+func (conn *NonBlockConnection) SetACLAsync(acls []ACL, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.SetACL(acls, h, opts...)
+	})
+}
+
+// MediaScanAsync is to check that the user data is readable, and if the end to end integrity is known
+// to the device, if the end to end integrity field is correct.
+func (conn *NonBlockConnection) MediaScanAsync(op *MediaOperation, pri Priority, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.MediaScan(op, pri, h, opts...)
+	})
+}
+
+// MediaOptimizeAsync performs optimizations of the media.
+func (conn *NonBlockConnection) MediaOptimizeAsync(op *MediaOperation, pri Priority, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.MediaOptimize(op, pri, h, opts...)
+	})
+}
+
+// SetPowerLevelAsync sets device power level.
+func (conn *NonBlockConnection) SetPowerLevelAsync(p PowerLevel, opts ...RequestOption) *Future[Status] {
+	return statusAsync(func(h *ResponseHandler) error {
+		return conn.SetPowerLevel(p, h, opts...)
+	})
+}
@@ -19,6 +19,7 @@ package kinetic
 
 import (
 	"sync"
+	"time"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
 )
@@ -28,51 +29,100 @@ import (
 type ResponseHandler struct {
 	callback Callback
 	done     bool
+	err      error // Client side error, if response message not received
 	cond     *sync.Cond
+	finished chan struct{}  // Closed when response message handled
+	seq      int64          // Sequence of request message, -1 if not sent
+	msgType  MessageType    // Message type of request message
+	deadline time.Time      // Deadline to receive response message
+	request  *request       // Request message to resubmit after reconnect, nil if not resubmittable
+	release  func()         // Releases outstanding request slot, nil if not holding any
+	log      *contextLogger // Logger of the connection request submitted to
+}
+
+// logger returns the logger with sequence and message type of the request.
+func (h *ResponseHandler) logger() *contextLogger {
+	return h.log.with(Fields{FieldSequence: h.seq, FieldMessageType: h.msgType.String()})
 }
 
 func (h *ResponseHandler) handle(cmd *kproto.Command, value []byte) error {
+	if h.isDone() {
+		return nil
+	}
 	if h.callback != nil {
 		if cmd.Status != nil && cmd.Status.Code != nil {
 			if cmd.GetStatus().GetCode() == kproto.Command_Status_SUCCESS {
 				h.callback.Success(cmd, value)
 			} else {
-				h.callback.Failure(cmd, getStatusFromProto(cmd))
+				s := getStatusFromProto(cmd)
+				s.MessageType, s.Sequence = h.msgType, h.seq
+				h.callback.Failure(cmd, s)
 			}
 		} else {
-			klog.Warn("Other status received")
-			klog.Info("%v", cmd)
+			h.logger().warn("Response without status received", Fields{"command": cmd.String()})
 		}
 
 	}
 	h.cond.L.Lock()
 	h.done = true
-	h.cond.Signal()
+	close(h.finished)
+	h.cond.Broadcast()
 	h.cond.L.Unlock()
 	return nil
 }
 
-func (h *ResponseHandler) fail(s Status) {
+// fail is called when response message can't be received for client side error.
+// If err is Status, it's replaced by *Error with details of the failed request.
+func (h *ResponseHandler) fail(s Status, err error) {
+	if h.isDone() {
+		return
+	}
+	s.MessageType, s.Sequence = h.msgType, h.seq
+	if _, ok := err.(Status); ok {
+		err = &Error{Status: s}
+	}
 	if h.callback != nil {
 		h.callback.Failure(nil, s)
 	}
 	h.cond.L.Lock()
 	h.done = true
-	h.cond.Signal()
+	h.err = err
+	close(h.finished)
+	h.cond.Broadcast()
 	h.cond.L.Unlock()
 }
 
-func (h *ResponseHandler) wait() {
+// releaseSlot releases the outstanding request slot held by the request, if any.
+func (h *ResponseHandler) releaseSlot() {
+	h.cond.L.Lock()
+	release := h.release
+	h.release = nil
+	h.cond.L.Unlock()
+	if release != nil {
+		release()
+	}
+}
+
+func (h *ResponseHandler) isDone() bool {
+	h.cond.L.Lock()
+	defer h.cond.L.Unlock()
+	return h.done
+}
+
+// wait blocks until response message handled, returns the client side error if any.
+func (h *ResponseHandler) wait() error {
 	h.cond.L.Lock()
-	if h.done == false {
+	for h.done == false {
 		h.cond.Wait()
 	}
+	err := h.err
 	h.cond.L.Unlock()
+	return err
 }
 
 // NewResponseHandler is helper function to build a ResponseHandler with call as the Callback.
 // For each operation, a unique ResponseHandler is required
 func NewResponseHandler(call Callback) *ResponseHandler {
-	h := &ResponseHandler{callback: call, done: false, cond: sync.NewCond(&sync.Mutex{})}
+	h := &ResponseHandler{callback: call, done: false, cond: sync.NewCond(&sync.Mutex{}), finished: make(chan struct{}), seq: -1}
 	return h
 }
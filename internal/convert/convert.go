@@ -0,0 +1,352 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+// Package convert converts between library types and kinetic protocol messages, for the server and
+// simulator of this module. Package kinetic keeps its own conversions unexported, so generated protocol
+// types stay out of its API, and it can't import this package. TestConvertMatchesInternal of package
+// kinetic checks both agree for every protocol value.
+package convert
+
+import (
+	kinetic "github.com/Kinetic/kinetic-go"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+var statusCodes = map[kinetic.StatusCode]kproto.Command_Status_StatusCode{
+	kinetic.RemoteNotAttempted:           kproto.Command_Status_NOT_ATTEMPTED,
+	kinetic.OK:                           kproto.Command_Status_SUCCESS,
+	kinetic.RemoteHMACError:              kproto.Command_Status_HMAC_FAILURE,
+	kinetic.RemoteNotAuthorized:          kproto.Command_Status_NOT_AUTHORIZED,
+	kinetic.RemoteClusterVersionMismatch: kproto.Command_Status_VERSION_FAILURE,
+	kinetic.RemoteInternalError:          kproto.Command_Status_INTERNAL_ERROR,
+	kinetic.RemoteHeaderRequired:         kproto.Command_Status_HEADER_REQUIRED,
+	kinetic.RemoteNotFound:               kproto.Command_Status_NOT_FOUND,
+	kinetic.RemoteVersionMismatch:        kproto.Command_Status_VERSION_MISMATCH,
+	kinetic.RemoteServiceBusy:            kproto.Command_Status_SERVICE_BUSY,
+	kinetic.RemoteExpired:                kproto.Command_Status_EXPIRED,
+	kinetic.RemoteDataError:              kproto.Command_Status_DATA_ERROR,
+	kinetic.RemotePermDataError:          kproto.Command_Status_PERM_DATA_ERROR,
+	kinetic.RemoteConnectionError:        kproto.Command_Status_REMOTE_CONNECTION_ERROR,
+	kinetic.RemoteNoSpace:                kproto.Command_Status_NO_SPACE,
+	kinetic.RemoteNoSuchHMACAlgorithm:    kproto.Command_Status_NO_SUCH_HMAC_ALGORITHM,
+	kinetic.RemoteInvalidRequest:         kproto.Command_Status_INVALID_REQUEST,
+	kinetic.RemoteNestedOperationErrors:  kproto.Command_Status_NESTED_OPERATION_ERRORS,
+	kinetic.RemoteDeviceLocked:           kproto.Command_Status_DEVICE_LOCKED,
+	kinetic.RemoteDeviceAlreadyUnlocked:  kproto.Command_Status_DEVICE_ALREADY_UNLOCKED,
+	kinetic.RemoteConnectionTerminated:   kproto.Command_Status_CONNECTION_TERMINATED,
+	kinetic.RemoteInvalidBatch:           kproto.Command_Status_INVALID_BATCH,
+	kinetic.RemoteHibernate:              kproto.Command_Status_HIBERNATE,
+	kinetic.RemoteShutdown:               kproto.Command_Status_SHUTDOWN,
+}
+
+var messageTypes = map[kinetic.MessageType]kproto.Command_MessageType{
+	kinetic.MessageGet:                   kproto.Command_GET,
+	kinetic.MessageGetResponse:           kproto.Command_GET_RESPONSE,
+	kinetic.MessagePut:                   kproto.Command_PUT,
+	kinetic.MessagePutResponse:           kproto.Command_PUT_RESPONSE,
+	kinetic.MessageDelete:                kproto.Command_DELETE,
+	kinetic.MessageDeleteResponse:        kproto.Command_DELETE_RESPONSE,
+	kinetic.MessageGetNext:               kproto.Command_GETNEXT,
+	kinetic.MessageGetNextResponse:       kproto.Command_GETNEXT_RESPONSE,
+	kinetic.MessageGetPrevious:           kproto.Command_GETPREVIOUS,
+	kinetic.MessageGetPreviousResponse:   kproto.Command_GETPREVIOUS_RESPONSE,
+	kinetic.MessageGetKeyRange:           kproto.Command_GETKEYRANGE,
+	kinetic.MessageGetKeyRangeResponse:   kproto.Command_GETKEYRANGE_RESPONSE,
+	kinetic.MessageGetVersion:            kproto.Command_GETVERSION,
+	kinetic.MessageGetVersionResponse:    kproto.Command_GETVERSION_RESPONSE,
+	kinetic.MessageSetup:                 kproto.Command_SETUP,
+	kinetic.MessageSetupResponse:         kproto.Command_SETUP_RESPONSE,
+	kinetic.MessageGetLog:                kproto.Command_GETLOG,
+	kinetic.MessageGetLogResponse:        kproto.Command_GETLOG_RESPONSE,
+	kinetic.MessageSecurity:              kproto.Command_SECURITY,
+	kinetic.MessageSecurityResponse:      kproto.Command_SECURITY_RESPONSE,
+	kinetic.MessagePeer2PeerPush:         kproto.Command_PEER2PEERPUSH,
+	kinetic.MessagePeer2PeerPushResponse: kproto.Command_PEER2PEERPUSH_RESPONSE,
+	kinetic.MessageNoop:                  kproto.Command_NOOP,
+	kinetic.MessageNoopResponse:          kproto.Command_NOOP_RESPONSE,
+	kinetic.MessageFlushAllData:          kproto.Command_FLUSHALLDATA,
+	kinetic.MessageFlushAllDataResponse:  kproto.Command_FLUSHALLDATA_RESPONSE,
+	kinetic.MessagePinOp:                 kproto.Command_PINOP,
+	kinetic.MessagePinOpResponse:         kproto.Command_PINOP_RESPONSE,
+	kinetic.MessageMediaScan:             kproto.Command_MEDIASCAN,
+	kinetic.MessageMediaScanResponse:     kproto.Command_MEDIASCAN_RESPONSE,
+	kinetic.MessageMediaOptimize:         kproto.Command_MEDIAOPTIMIZE,
+	kinetic.MessageMediaOptimizeResponse: kproto.Command_MEDIAOPTIMIZE_RESPONSE,
+	kinetic.MessageStartBatch:            kproto.Command_START_BATCH,
+	kinetic.MessageStartBatchResponse:    kproto.Command_START_BATCH_RESPONSE,
+	kinetic.MessageEndBatch:              kproto.Command_END_BATCH,
+	kinetic.MessageEndBatchResponse:      kproto.Command_END_BATCH_RESPONSE,
+	kinetic.MessageAbortBatch:            kproto.Command_ABORT_BATCH,
+	kinetic.MessageAbortBatchResponse:    kproto.Command_ABORT_BATCH_RESPONSE,
+	kinetic.MessageSetPowerLevel:         kproto.Command_SET_POWER_LEVEL,
+	kinetic.MessageSetPowerLevelResponse: kproto.Command_SET_POWER_LEVEL_RESPONSE,
+}
+
+var logTypes = map[kinetic.LogType]kproto.Command_GetLog_Type{
+	kinetic.LogTypeUtilizations:  kproto.Command_GetLog_UTILIZATIONS,
+	kinetic.LogTypeTemperatures:  kproto.Command_GetLog_TEMPERATURES,
+	kinetic.LogTypeCapacities:    kproto.Command_GetLog_CAPACITIES,
+	kinetic.LogTypeConfiguration: kproto.Command_GetLog_CONFIGURATION,
+	kinetic.LogTypeStatistics:    kproto.Command_GetLog_STATISTICS,
+	kinetic.LogTypeMessages:      kproto.Command_GetLog_MESSAGES,
+	kinetic.LogTypeLimits:        kproto.Command_GetLog_LIMITS,
+	kinetic.LogTypeDevice:        kproto.Command_GetLog_DEVICE,
+}
+
+var algorithms = map[kinetic.Algorithm]kproto.Command_Algorithm{
+	kinetic.AlgorithmSHA1:   kproto.Command_SHA1,
+	kinetic.AlgorithmSHA2:   kproto.Command_SHA2,
+	kinetic.AlgorithmSHA3:   kproto.Command_SHA3,
+	kinetic.AlgorithmCRC32C: kproto.Command_CRC32C,
+	kinetic.AlgorithmCRC64:  kproto.Command_CRC64,
+	kinetic.AlgorithmCRC32:  kproto.Command_CRC32,
+}
+
+var syncs = map[kinetic.Synchronization]kproto.Command_Synchronization{
+	kinetic.SyncWriteThrough: kproto.Command_WRITETHROUGH,
+	kinetic.SyncWriteBack:    kproto.Command_WRITEBACK,
+	kinetic.SyncFlush:        kproto.Command_FLUSH,
+}
+
+var powerLevels = map[kinetic.PowerLevel]kproto.Command_PowerLevel{
+	kinetic.PowerLevelOperational: kproto.Command_OPERATIONAL,
+	kinetic.PowerLevelHibernate:   kproto.Command_HIBERNATE,
+	kinetic.PowerLevelShutdown:    kproto.Command_SHUTDOWN,
+	kinetic.PowerLevelFail:        kproto.Command_FAIL,
+}
+
+var priorities = map[kinetic.Priority]kproto.Command_Priority{
+	kinetic.PriorityLowest:  kproto.Command_LOWEST,
+	kinetic.PriorityLower:   kproto.Command_LOWER,
+	kinetic.PriorityNormal:  kproto.Command_NORMAL,
+	kinetic.PriorityHigher:  kproto.Command_HIGHER,
+	kinetic.PriorityHighest: kproto.Command_HIGHEST,
+}
+
+var aclPermissions = map[kinetic.ACLPermission]kproto.Command_Security_ACL_Permission{
+	kinetic.ACLPermissionRead:            kproto.Command_Security_ACL_READ,
+	kinetic.ACLPermissionWrite:           kproto.Command_Security_ACL_WRITE,
+	kinetic.ACLPermissionDelete:          kproto.Command_Security_ACL_DELETE,
+	kinetic.ACLPermissionRange:           kproto.Command_Security_ACL_RANGE,
+	kinetic.ACLPermissionSetup:           kproto.Command_Security_ACL_SETUP,
+	kinetic.ACLPermissionP2POP:           kproto.Command_Security_ACL_P2POP,
+	kinetic.ACLPermissionGetLog:          kproto.Command_Security_ACL_GETLOG,
+	kinetic.ACLPermissionSecurity:        kproto.Command_Security_ACL_SECURITY,
+	kinetic.ACLPermissionPowerManagement: kproto.Command_Security_ACL_POWER_MANAGEMENT,
+}
+
+// Reverse tables, from protocol to library values.
+var (
+	statusCodesFromProto    = reverse(statusCodes)
+	messageTypesFromProto   = reverse(messageTypes)
+	logTypesFromProto       = reverse(logTypes)
+	algorithmsFromProto     = reverse(algorithms)
+	syncsFromProto          = reverse(syncs)
+	powerLevelsFromProto    = reverse(powerLevels)
+	prioritiesFromProto     = reverse(priorities)
+	aclPermissionsFromProto = reverse(aclPermissions)
+)
+
+func reverse[K, V comparable](m map[K]V) map[V]K {
+	r := make(map[V]K, len(m))
+	for k, v := range m {
+		r[v] = k
+	}
+	return r
+}
+
+// StatusCodeToProto returns protocol status code of StatusCode.
+// Client side status codes have no protocol status code, INVALID_STATUS_CODE is returned.
+func StatusCodeToProto(c kinetic.StatusCode) kproto.Command_Status_StatusCode {
+	if p, ok := statusCodes[c]; ok {
+		return p
+	}
+	return kproto.Command_Status_INVALID_STATUS_CODE
+}
+
+// StatusCodeFromProto returns StatusCode of protocol status code, RemoteOtherError if unknown.
+func StatusCodeFromProto(c kproto.Command_Status_StatusCode) kinetic.StatusCode {
+	if s, ok := statusCodesFromProto[c]; ok {
+		return s
+	}
+	return kinetic.RemoteOtherError
+}
+
+// MessageTypeToProto returns protocol message type of MessageType.
+func MessageTypeToProto(m kinetic.MessageType) kproto.Command_MessageType {
+	if p, ok := messageTypes[m]; ok {
+		return p
+	}
+	return kproto.Command_INVALID_MESSAGE_TYPE
+}
+
+// MessageTypeFromProto returns MessageType of protocol message type.
+func MessageTypeFromProto(m kproto.Command_MessageType) kinetic.MessageType {
+	return messageTypesFromProto[m]
+}
+
+// LogTypeFromProto returns LogType of protocol log type.
+func LogTypeFromProto(l kproto.Command_GetLog_Type) kinetic.LogType {
+	return logTypesFromProto[l]
+}
+
+// PowerLevelFromProto returns PowerLevel of protocol power level.
+func PowerLevelFromProto(p kproto.Command_PowerLevel) kinetic.PowerLevel {
+	return powerLevelsFromProto[p]
+}
+
+// ACLFromProto returns ACL of SECURITY request. Algo is HMAC-SHA1, the only algorithm of protocol.
+func ACLFromProto(a *kproto.Command_Security_ACL) kinetic.ACL {
+	acl := kinetic.ACL{
+		Identity:    a.GetIdentity(),
+		Key:         a.GetKey(),
+		Algo:        kinetic.ACLAlgorithmHMACSHA1,
+		MaxPriority: prioritiesFromProto[a.GetMaxPriority()],
+	}
+	for _, s := range a.GetScope() {
+		scope := kinetic.ACLScope{Offset: s.GetOffset(), Value: s.GetValue(), TLSRequired: s.GetTlsRequired()}
+		for _, p := range s.GetPermission() {
+			scope.Permissions = append(scope.Permissions, aclPermissionsFromProto[p])
+		}
+		acl.Scopes = append(acl.Scopes, scope)
+	}
+	return acl
+}
+
+// RecordFromProto returns Record of PUT, DELETE or GET request key value and value.
+// Record.Version is the expected version of object, from DbVersion.
+func RecordFromProto(kv *kproto.Command_KeyValue, value []byte) *kinetic.Record {
+	return &kinetic.Record{
+		Key:        kv.GetKey(),
+		Value:      value,
+		Version:    kv.GetDbVersion(),
+		NewVersion: kv.GetNewVersion(),
+		Tag:        kv.GetTag(),
+		Algo:       algorithmsFromProto[kv.GetAlgorithm()],
+		Sync:       syncsFromProto[kv.GetSynchronization()],
+		Force:      kv.GetForce(),
+		MetaOnly:   kv.GetMetadataOnly(),
+	}
+}
+
+// RecordToProto returns key value of GET response for Record, value is sent separately.
+func RecordToProto(r *kinetic.Record) *kproto.Command_KeyValue {
+	kv := &kproto.Command_KeyValue{
+		Key:       r.Key,
+		DbVersion: r.Version,
+		Tag:       r.Tag,
+	}
+	if a, ok := algorithms[r.Algo]; ok {
+		kv.Algorithm = a.Enum()
+	}
+	return kv
+}
+
+// KeyRangeFromProto returns KeyRange of GETKEYRANGE request range.
+func KeyRangeFromProto(r *kproto.Command_Range) *kinetic.KeyRange {
+	return &kinetic.KeyRange{
+		StartKey:          r.GetStartKey(),
+		EndKey:            r.GetEndKey(),
+		StartKeyInclusive: r.GetStartKeyInclusive(),
+		EndKeyInclusive:   r.GetEndKeyInclusive(),
+		Reverse:           r.GetReverse(),
+		Max:               r.GetMaxReturned(),
+	}
+}
+
+// LogToProto returns GETLOG response body of Log, only parts of Log not nil are included.
+// Types of the response is not set.
+func LogToProto(l *kinetic.Log) *kproto.Command_GetLog {
+	getlog := &kproto.Command_GetLog{Messages: l.Messages}
+
+	for _, u := range l.Utilizations {
+		getlog.Utilizations = append(getlog.Utilizations, &kproto.Command_GetLog_Utilization{
+			Name:  proto.String(u.Name),
+			Value: proto.Float32(u.Value),
+		})
+	}
+	for _, t := range l.Temperatures {
+		getlog.Temperatures = append(getlog.Temperatures, &kproto.Command_GetLog_Temperature{
+			Name:    proto.String(t.Name),
+			Current: proto.Float32(t.Current),
+			Minimum: proto.Float32(t.Minimum),
+			Maximum: proto.Float32(t.Maximum),
+			Target:  proto.Float32(t.Target),
+		})
+	}
+	if l.Capacity != nil {
+		getlog.Capacity = &kproto.Command_GetLog_Capacity{
+			NominalCapacityInBytes: proto.Uint64(l.Capacity.CapacityInBytes),
+			PortionFull:            proto.Float32(l.Capacity.PortionFull),
+		}
+	}
+	if c := l.Configuration; c != nil {
+		conf := &kproto.Command_GetLog_Configuration{
+			Vendor:                  proto.String(c.Vendor),
+			Model:                   proto.String(c.Model),
+			SerialNumber:            c.SerialNumber,
+			WorldWideName:           c.WorldWideName,
+			Version:                 proto.String(c.Version),
+			CompilationDate:         proto.String(c.CompilationDate),
+			SourceHash:              proto.String(c.SourceHash),
+			ProtocolVersion:         proto.String(c.ProtocolVersion),
+			ProtocolCompilationDate: proto.String(c.ProtocolCompilationDate),
+			ProtocolSourceHash:      proto.String(c.ProtocolSourceHash),
+			Port:                    proto.Int32(c.Port),
+			TlsPort:                 proto.Int32(c.TLSPort),
+			CurrentPowerLevel:       powerLevels[c.CurrentPowerLevel].Enum(),
+		}
+		for _, inf := range c.Interface {
+			conf.Interface = append(conf.Interface, &kproto.Command_GetLog_Configuration_Interface{
+				Name:        proto.String(inf.Name),
+				MAC:         inf.MAC,
+				Ipv4Address: inf.Ipv4Addr,
+				Ipv6Address: inf.Ipv6Addr,
+			})
+		}
+		getlog.Configuration = conf
+	}
+	for _, s := range l.Statistics {
+		getlog.Statistics = append(getlog.Statistics, &kproto.Command_GetLog_Statistics{
+			MessageType: MessageTypeToProto(s.Type).Enum(),
+			Count:       proto.Uint64(s.Count),
+			Bytes:       proto.Uint64(s.Bytes),
+		})
+	}
+	if m := l.Limits; m != nil {
+		getlog.Limits = &kproto.Command_GetLog_Limits{
+			MaxKeySize:                  proto.Uint32(m.MaxKeySize),
+			MaxValueSize:                proto.Uint32(m.MaxValueSize),
+			MaxVersionSize:              proto.Uint32(m.MaxVersionSize),
+			MaxTagSize:                  proto.Uint32(m.MaxTagSize),
+			MaxConnections:              proto.Uint32(m.MaxConnections),
+			MaxOutstandingReadRequests:  proto.Uint32(m.MaxOutstandingReadRequests),
+			MaxOutstandingWriteRequests: proto.Uint32(m.MaxOutstandingWriteRequests),
+			MaxMessageSize:              proto.Uint32(m.MaxMessageSize),
+			MaxKeyRangeCount:            proto.Uint32(m.MaxKeyRangeCount),
+			MaxIdentityCount:            proto.Uint32(m.MaxIdentityCount),
+			MaxPinSize:                  proto.Uint32(m.MaxPinSize),
+			MaxOperationCountPerBatch:   proto.Uint32(m.MaxOperationCountPerBatch),
+			MaxBatchCountPerDevice:      proto.Uint32(m.MaxBatchCountPerDevice),
+		}
+	}
+	if l.Device != nil {
+		getlog.Device = &kproto.Command_GetLog_Device{Name: l.Device.Name}
+	}
+	return getlog
+}
@@ -0,0 +1,47 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package convert
+
+import (
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+func TestReverse(t *testing.T) {
+	// Each protocol value maps back to exactly one library value.
+	if len(statusCodesFromProto) != len(statusCodes) || len(messageTypesFromProto) != len(messageTypes) ||
+		len(logTypesFromProto) != len(logTypes) || len(algorithmsFromProto) != len(algorithms) ||
+		len(syncsFromProto) != len(syncs) || len(powerLevelsFromProto) != len(powerLevels) ||
+		len(prioritiesFromProto) != len(priorities) || len(aclPermissionsFromProto) != len(aclPermissions) {
+		t.Fatal("Conversion table maps two library values to one protocol value")
+	}
+
+	for c := range statusCodes {
+		if StatusCodeFromProto(StatusCodeToProto(c)) != c {
+			t.Fatal("StatusCode round trip mismatch", c)
+		}
+	}
+	if StatusCodeToProto(kinetic.ClientIOError) != kproto.Command_Status_INVALID_STATUS_CODE {
+		t.Fatal("Client status code expect INVALID_STATUS_CODE")
+	}
+	if StatusCodeFromProto(kproto.Command_Status_INVALID_STATUS_CODE) != kinetic.RemoteOtherError {
+		t.Fatal("Unknown protocol status code expect RemoteOtherError")
+	}
+}
@@ -23,8 +23,11 @@ For details about kinetic protocol, please refer to https://github.com/Kinetic/k
 package kinetic
 
 import (
+	"context"
 	"io"
+	"math/rand"
 	"os"
+	"time"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
 	"github.com/Sirupsen/logrus"
@@ -59,25 +62,265 @@ const (
 	LogLevelDebug LogLevel = LogLevel(logrus.DebugLevel)
 )
 
-// SetLogLevel sets kinetic library log level
+// SetLogLevel sets kinetic library log level of default Logger
 func SetLogLevel(l LogLevel) {
 	klog.Level = logrus.Level(l)
 }
 
-// SetLogOutput sets kinetic library log output
+// SetLogOutput sets kinetic library log output of default Logger
 func SetLogOutput(out io.Writer) {
 	klog.Out = out
 }
 
 // ClientOptions specify connection options to kinetic device.
 type ClientOptions struct {
-	Host           string // Kinetic device IP address
-	Port           int    // Network port to connect, if UseSSL is true, this port should be the TlsPort
-	User           int64  // User Id
-	Hmac           []byte
-	UseSSL         bool  // Use SSL connection, or plain connection
+	Host   string // Kinetic device IP address
+	Port   int    // Network port to connect, if UseSSL is true, this port should be the TlsPort
+	User   int64  // User Id
+	Hmac   []byte
+	UseSSL bool // Use SSL connection, or plain connection
+	// SSL connection verification and client certificates, nil to skip device certificate verification.
+	TLS            *TLSOptions
 	Timeout        int64 // Network timeout in millisecond
-	RequestTimeout int64 // Operation request timeout in millisecond
+	RequestTimeout int64 // Operation request timeout in millisecond, default for ReadTimeout and WriteTimeout
+	ReadTimeout    int64 // Timeout waiting for response message of each request in millisecond
+	WriteTimeout   int64 // Timeout sending request message to device in millisecond
+	IdleTimeout    int64 // Close connection if no request outstanding for this long in millisecond, 0 for never
+	// Reconnect policy on network failure, nil to disable reconnect.
+	Reconnect *ReconnectPolicy
+	// How requests are admitted when device MaxOutstandingReadRequests or MaxOutstandingWriteRequests reached.
+	FlowControl FlowControl
+	// Retry policy of BlockConnection for requests failed with transient status, nil to disable retry.
+	Retry *RetryPolicy
+	// Logger for this connection, nil to use package-wide Logger set by SetLogger.
+	Logger Logger
+	// Maximum message and value size of response message, 0 for device limits from handshake LimitsLog
+	// with slack for protocol overhead. Response exceeding the limits fails the connection with ClientIOError.
+	MaxResponseMessageSize uint32
+	MaxResponseValueSize   uint32
+}
+
+// ReconnectPolicy specify how connection reconnects to kinetic device after network failure.
+// After reconnected, handshake is done again and client cluster version is restored.
+// Requests in flight at the time of failure fail with ClientConnectionReset, unless ResubmitReads is true
+// and the request is idempotent read (GET, GETNEXT, GETPREVIOUS, GETVERSION, GETKEYRANGE, GETLOG, NOOP).
+// Requests submitted while reconnecting fail immediately with ClientConnectionReset.
+type ReconnectPolicy struct {
+	MaxAttempts   int           // Maximum reconnect attempts for each network failure, 0 for unlimited
+	InitialDelay  time.Duration // Delay before first reconnect attempt
+	MaxDelay      time.Duration // Maximum delay between reconnect attempts, 0 for no limit
+	Multiplier    float64       // Backoff multiplier for delay between attempts, default 2
+	ResubmitReads bool          // Resubmit idempotent read requests in flight after reconnected
+}
+
+// nextDelay returns the delay before next reconnect attempt.
+func (p *ReconnectPolicy) nextDelay(delay time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	delay = time.Duration(float64(delay) * multiplier)
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// RetryPolicy specify how BlockConnection retries requests failed with transient status.
+// Only requests with MessageType in RetryableTypes are retried. PUT and DELETE are retried only if they carry
+// version precondition, ie. Record.Force is false, so the write can't be applied twice. Record.Version nil
+// without Force is a create-only precondition.
+// PUT and DELETE are not retried on ClientIOError, ClientConnectionReset or ClientRequestTimeout, as the
+// first attempt may have been applied, and the retry would fail with version conflict against it.
+// Setup, security, pin operations, P2P push and batch operations are never retried.
+type RetryPolicy struct {
+	MaxAttempts    int           // Maximum attempts for each request, including the first attempt
+	InitialDelay   time.Duration // Delay before first retry, default 100ms
+	MaxDelay       time.Duration // Maximum delay between retries, 0 for no limit
+	Multiplier     float64       // Backoff multiplier for delay between retries, default 2
+	Jitter         float64       // Randomize each delay by up to +/- Jitter fraction, eg 0.2 for 20%
+	RetryableCodes []StatusCode  // Status codes to retry, nil for DefaultRetryableCodes
+	RetryableTypes []MessageType // Message types safe to retry, nil for DefaultRetryableTypes
+}
+
+// DefaultRetryableCodes are the transient status codes retried if RetryPolicy.RetryableCodes is nil,
+// and reported by IsRetryable.
+var DefaultRetryableCodes = []StatusCode{
+	RemoteServiceBusy, RemoteExpired, RemoteConnectionError, ClientIOError, ClientConnectionReset, ClientRequestTimeout,
+}
+
+// DefaultRetryableTypes are the message types retried if RetryPolicy.RetryableTypes is nil.
+var DefaultRetryableTypes = []MessageType{
+	MessageGet, MessageGetNext, MessageGetPrevious, MessageGetVersion, MessageGetKeyRange,
+	MessageGetLog, MessageNoop, MessageFlushAllData, MessagePut, MessageDelete,
+}
+
+// retryable returns true if request of message type t failed with code can be retried.
+// precondition is true if write request carries version precondition.
+func (p *RetryPolicy) retryable(t MessageType, precondition bool, code StatusCode) bool {
+	if (t == MessagePut || t == MessageDelete) && (!precondition || isAmbiguous(code)) {
+		return false
+	}
+	types := p.RetryableTypes
+	if types == nil {
+		types = DefaultRetryableTypes
+	}
+	codes := p.RetryableCodes
+	if codes == nil {
+		codes = DefaultRetryableCodes
+	}
+	return containsMessageType(types, t) && containsStatusCode(codes, code)
+}
+
+// isAmbiguous returns true for client side status codes, the request failed with may have been applied by device.
+func isAmbiguous(code StatusCode) bool {
+	switch code {
+	case ClientIOError, ClientConnectionReset, ClientRequestTimeout:
+		return true
+	}
+	return false
+}
+
+// delay returns the delay before retry attempt, attempt starts from 1 for first retry.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialDelay)
+	if delay <= 0 {
+		delay = float64(100 * time.Millisecond)
+	}
+	for k := 1; k < attempt; k++ {
+		delay *= multiplier
+		if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+func containsMessageType(types []MessageType, t MessageType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatusCode(codes []StatusCode, c StatusCode) bool {
+	for _, v := range codes {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOptions holds the optional settings for single request to kinetic device.
+type requestOptions struct {
+	ctx            context.Context
+	requestTimeout time.Duration
+	timeout        *int64
+	earlyExit      *bool
+	timeQuanta     *int64
+	priority       *Priority
+}
+
+// RequestOption sets optional settings for single request to kinetic device.
+// All NonBlockConnection and BlockConnection operations accept RequestOption.
+type RequestOption func(*requestOptions)
+
+// WithTimeout sets the device side timeout for request. If the timeout expires, kinetic device
+// returns RemoteServiceBusy if request was still queued, RemoteExpired if a long running operation was stopped,
+// or RemoteDataError if error recovery was not complete.
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		ms := int64(timeout / time.Millisecond)
+		o.timeout = &ms
+	}
+}
+
+// WithRequestTimeout overrides the connection ReadTimeout and WriteTimeout for single request.
+// If response is not received in time, the request alone fails with ClientRequestTimeout.
+// Use it for operations known to take long on kinetic device, or for devices slow to respond, eg. hibernating.
+func WithRequestTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.requestTimeout = timeout
+	}
+}
+
+// WithEarlyExit requests kinetic device not to attempt multi revolution recoveries,
+// RemoteDataError is returned instead.
+func WithEarlyExit() RequestOption {
+	return func(o *requestOptions) {
+		earlyExit := true
+		o.earlyExit = &earlyExit
+	}
+}
+
+// WithTimeQuanta hints kinetic device how long a long running operation should run before
+// yielding to higher priority operations.
+func WithTimeQuanta(quanta time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		ms := int64(quanta / time.Millisecond)
+		o.timeQuanta = &ms
+	}
+}
+
+// WithPriority sets the request priority. Requests with higher priority execute before
+// requests with lower priority on kinetic device.
+func WithPriority(p Priority) RequestOption {
+	return func(o *requestOptions) {
+		o.priority = &p
+	}
+}
+
+// WithContext associates the request with ctx. If ctx is cancelled or its deadline expires
+// before response received, the request is abandoned and ctx.Err() returned, the connection
+// is still usable for other requests.
+func WithContext(ctx context.Context) RequestOption {
+	return func(o *requestOptions) {
+		o.ctx = ctx
+	}
+}
+
+// newRequestOptions collects settings from list of RequestOption.
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	o := &requestOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// applyHeader sets the request options into kinetic command header.
+func (o *requestOptions) applyHeader(header *kproto.Command_Header) {
+	if o.timeout != nil {
+		header.Timeout = o.timeout
+	}
+	if o.earlyExit != nil {
+		header.EarlyExit = o.earlyExit
+	}
+	if o.timeQuanta != nil {
+		header.TimeQuanta = o.timeQuanta
+	}
+	if o.priority != nil {
+		p := convertPriorityToProto(*o.priority)
+		header.Priority = &p
+	}
 }
 
 // MessageType defines the top level kinetic command message type.
@@ -544,15 +787,19 @@ func convertPriorityFromProto(p kproto.Command_Priority) Priority {
 }
 
 // Record structure defines information for an object stored on kinetic device.
+// For Put and Delete, Version is the version expected on kinetic device, and the operation
+// fails with VersionConflictError if it doesn't match, unless Force is true.
+// For Put, NewVersion is the version the object will have after the operation.
 type Record struct {
-	Key      []byte
-	Value    []byte
-	Version  []byte
-	Tag      []byte
-	Algo     Algorithm
-	Sync     Synchronization
-	Force    bool
-	MetaOnly bool
+	Key        []byte
+	Value      []byte
+	Version    []byte
+	NewVersion []byte
+	Tag        []byte
+	Algo       Algorithm
+	Sync       Synchronization
+	Force      bool
+	MetaOnly   bool
 }
 
 // KeyRange structure defines the range for GetRange operation.
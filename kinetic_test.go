@@ -19,6 +19,7 @@ package kinetic
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 )
 
@@ -253,6 +254,49 @@ func ExampleNonBlockConnection_multiplePut() {
 	}
 }
 
+func ExampleNonBlockConnection_futures() {
+	// Client options
+	var option = ClientOptions{
+		Host: "127.0.0.1",
+		Port: 8123,
+		User: 1,
+		Hmac: []byte("asdfasdf")}
+
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	// PUT objects, all requests are pipelined before waiting for results
+	puts := make([]*Future[Status], 0, 10)
+	for id := 1; id <= 10; id++ {
+		entry := Record{
+			Key:   []byte(fmt.Sprintf("FutureObject-%05d", id)),
+			Value: []byte("ABCDEFG"),
+			Sync:  SyncWriteThrough,
+			Algo:  AlgorithmSHA1,
+			Force: true,
+		}
+		puts = append(puts, conn.PutAsync(&entry))
+	}
+	for _, f := range puts {
+		if _, err := f.Wait(ctx); err != nil {
+			fmt.Println("NonBlocking Put Failure", err)
+		}
+	}
+
+	// GET
+	record, err := conn.GetAsync([]byte("FutureObject-00001")).Wait(ctx)
+	if err != nil {
+		fmt.Println("NonBlocking Get Failure", err)
+	} else {
+		fmt.Println(string(record.Value))
+	}
+}
+
 func ExampleBlockConnection_SetACL() {
 	// Set the log leverl to debug
 	SetLogLevel(LogLevelDebug)
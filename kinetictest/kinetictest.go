@@ -0,0 +1,145 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+/*
+Package kinetictest provides a simulated kinetic drive for tests of kinetic clients.
+
+	func TestService(t *testing.T) {
+		drive := kinetictest.NewDrive(t)
+		drive.Seed(map[string]kinetic.Record{
+			"user/1": {Value: []byte("alice"), Version: []byte("v1")},
+		})
+
+		conn, err := kinetic.NewBlockConnection(drive.Options())
+		...
+
+		records := drive.Snapshot()
+		...
+	}
+
+The drive is closed automatically when the test finishes.
+*/
+package kinetictest
+
+import (
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+// Drive is a simulated kinetic drive listening on random local ports.
+// Simulator methods are available to inject failures, eg. Notify, Disconnect and SetLatency.
+type Drive struct {
+	*simulator.Simulator
+	tb       testing.TB
+	identity int64
+	key      []byte
+}
+
+// NewDrive starts a simulated drive with default configuration, it's closed when tb finishes.
+func NewDrive(tb testing.TB) *Drive {
+	tb.Helper()
+	return NewDriveWithConfig(tb, simulator.Config{})
+}
+
+// NewDriveWithConfig starts a simulated drive with cfg, it's closed when tb finishes.
+// Options uses the first ACL of cfg, or the default identity if cfg has no ACL.
+func NewDriveWithConfig(tb testing.TB, cfg simulator.Config) *Drive {
+	tb.Helper()
+	sim, err := simulator.Start(cfg)
+	if err != nil {
+		tb.Fatal("Can't start simulated kinetic drive", err)
+	}
+	tb.Cleanup(func() { sim.Close() })
+
+	d := &Drive{Simulator: sim, tb: tb, identity: simulator.DefaultIdentity, key: simulator.DefaultKey}
+	if len(cfg.ACLs) > 0 {
+		d.identity, d.key = cfg.ACLs[0].Identity, cfg.ACLs[0].Key
+	}
+	return d
+}
+
+// Options returns ClientOptions to connect to the drive on plain TCP port.
+func (d *Drive) Options() kinetic.ClientOptions {
+	return kinetic.ClientOptions{
+		Host: d.Host(),
+		Port: d.Port(),
+		User: d.identity,
+		Hmac: d.key,
+	}
+}
+
+// TLSOptions returns ClientOptions to connect to the drive on TLS port, drive certificate is verified.
+func (d *Drive) TLSOptions() kinetic.ClientOptions {
+	op := d.Options()
+	op.Port = d.TLSPort()
+	op.UseSSL = true
+	op.TLS = &kinetic.TLSOptions{RootCAs: d.CertPool()}
+	return op
+}
+
+// NewBlockConnection connects to the drive with Options, connection is closed when tb finishes.
+func (d *Drive) NewBlockConnection() *kinetic.BlockConnection {
+	d.tb.Helper()
+	conn, err := kinetic.NewBlockConnection(d.Options())
+	if err != nil {
+		d.tb.Fatal("Can't connect to simulated kinetic drive", err)
+	}
+	d.tb.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// NewNonBlockConnection connects to the drive with Options, connection is closed when tb finishes.
+func (d *Drive) NewNonBlockConnection() *kinetic.NonBlockConnection {
+	d.tb.Helper()
+	conn, err := kinetic.NewNonBlockConnection(d.Options())
+	if err != nil {
+		d.tb.Fatal("Can't connect to simulated kinetic drive", err)
+	}
+	d.tb.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Seed stores records on the drive, keyed by map key. Record Key, Value, Version, Tag and Algo
+// are stored as is, other fields are ignored.
+func (d *Drive) Seed(records map[string]kinetic.Record) {
+	for key, r := range records {
+		r.Key = []byte(key)
+		d.Put(r)
+	}
+}
+
+// Snapshot returns all records stored on the drive, keyed by key.
+func (d *Drive) Snapshot() map[string]kinetic.Record {
+	objects := d.Objects()
+	records := make(map[string]kinetic.Record, len(objects))
+	for _, r := range objects {
+		records[string(r.Key)] = r
+	}
+	return records
+}
+
+// Keys returns keys of all records stored on the drive, in drive order.
+func (d *Drive) Keys() []string {
+	objects := d.Objects()
+	keys := make([]string, len(objects))
+	for k, o := range objects {
+		keys[k] = string(o.Key)
+	}
+	return keys
+}
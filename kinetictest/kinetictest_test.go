@@ -0,0 +1,70 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetictest_test
+
+import (
+	"bytes"
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/kinetictest"
+)
+
+func TestDriveSeedSnapshot(t *testing.T) {
+	drive := kinetictest.NewDrive(t)
+	drive.Seed(map[string]kinetic.Record{
+		"user/1": {Value: []byte("alice"), Version: []byte("v1"), Algo: kinetic.AlgorithmSHA1},
+		"user/2": {Value: []byte("bob")},
+	})
+
+	conn := drive.NewBlockConnection()
+	record, status, err := conn.Get([]byte("user/1"))
+	if err != nil || status.Code != kinetic.OK || !bytes.Equal(record.Value, []byte("alice")) || !bytes.Equal(record.Version, []byte("v1")) {
+		t.Fatal("Get seeded record Failure", err, status.String())
+	}
+	if status, err := conn.Delete(&kinetic.Record{Key: []byte("user/2"), Force: true, Sync: kinetic.SyncWriteThrough}); err != nil || status.Code != kinetic.OK {
+		t.Fatal("Delete Failure", err, status.String())
+	}
+
+	records := drive.Snapshot()
+	if len(records) != 1 || records["user/1"].Algo != kinetic.AlgorithmSHA1 {
+		t.Fatal("Snapshot expect only user/1", records)
+	}
+	if keys := drive.Keys(); len(keys) != 1 || keys[0] != "user/1" {
+		t.Fatal("Keys expect only user/1", keys)
+	}
+}
+
+func TestDriveOptions(t *testing.T) {
+	drive := kinetictest.NewDrive(t)
+
+	// Options work unchanged with library constructors
+	for _, op := range []kinetic.ClientOptions{drive.Options(), drive.TLSOptions()} {
+		conn, err := kinetic.NewNonBlockConnection(op)
+		if err != nil {
+			t.Fatal("Nonblocking connection Failure", err)
+		}
+		callback := &kinetic.GenericCallback{}
+		h := kinetic.NewResponseHandler(callback)
+		conn.NoOp(h)
+		if err := conn.Listen(h); err != nil || callback.Status().Code != kinetic.OK {
+			t.Fatal("Nonblocking NoOp Failure", err, callback.Status().String())
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,156 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Kinetic/kinetic-go/codec"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+const (
+	// Maximum response message and value size if device doesn't report its limits, eg. for handshake.
+	defaultMaxResponseMessageSize = 4 << 20
+	defaultMaxResponseValueSize   = 4 << 20
+	// Slack added to device limits for response, for protocol overhead of response message.
+	responseSlack = 64 << 10
+	// Maximum size of connection ID, sequence and cluster version in command header, which are set after
+	// validate when request is sent, and the growth of header length prefix.
+	requestHeaderAllowance = 3*(1+binary.MaxVarintLen64) + 1
+)
+
+// LimitError is returned when request exceeds kinetic device limits, as reported
+// by LimitsLog in handshake. Request is not sent to kinetic device.
+type LimitError struct {
+	Limit string // Name of the violated limit in LimitsLog, eg. "MaxKeySize"
+	Max   uint32 // Limit value from LimitsLog
+	Size  int    // Size or count in request
+}
+
+// Error returns the detail message of violated limit.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("request exceeds device limit %s: %d > %d", e.Limit, e.Size, e.Max)
+}
+
+// Unwrap returns ClientLimitExceeded, so LimitError can be matched by errors.Is.
+func (e *LimitError) Unwrap() error {
+	return ClientLimitExceeded
+}
+
+// checkLimit returns LimitError if size exceeds max. Limit value 0 means device doesn't report the limit.
+func checkLimit(limit string, max uint32, size int) error {
+	if max > 0 && size > int(max) {
+		return &LimitError{Limit: limit, Max: max, Size: size}
+	}
+	return nil
+}
+
+// validate checks request against kinetic device limits, before sending to device.
+func validate(limits *LimitsLog, msg *kproto.Message, cmd *kproto.Command, value []byte) error {
+	if limits == nil {
+		return nil
+	}
+
+	checks := make([]error, 0, 8)
+
+	if kv := cmd.GetBody().GetKeyValue(); kv != nil {
+		checks = append(checks,
+			checkLimit("MaxKeySize", limits.MaxKeySize, len(kv.GetKey())),
+			checkLimit("MaxVersionSize", limits.MaxVersionSize, len(kv.GetDbVersion())),
+			checkLimit("MaxVersionSize", limits.MaxVersionSize, len(kv.GetNewVersion())),
+			checkLimit("MaxTagSize", limits.MaxTagSize, len(kv.GetTag())))
+	}
+
+	if r := cmd.GetBody().GetRange(); r != nil {
+		checks = append(checks,
+			checkLimit("MaxKeySize", limits.MaxKeySize, len(r.GetStartKey())),
+			checkLimit("MaxKeySize", limits.MaxKeySize, len(r.GetEndKey())),
+			checkLimit("MaxKeyRangeCount", limits.MaxKeyRangeCount, int(r.GetMaxReturned())))
+	}
+
+	for _, op := range cmd.GetBody().GetP2POperation().GetOperation() {
+		checks = append(checks,
+			checkLimit("MaxKeySize", limits.MaxKeySize, len(op.GetKey())),
+			checkLimit("MaxKeySize", limits.MaxKeySize, len(op.GetNewKey())),
+			checkLimit("MaxVersionSize", limits.MaxVersionSize, len(op.GetVersion())))
+	}
+
+	if sec := cmd.GetBody().GetSecurity(); sec != nil {
+		checks = append(checks,
+			checkLimit("MaxIdentityCount", limits.MaxIdentityCount, len(sec.GetAcl())),
+			checkLimit("MaxPinSize", limits.MaxPinSize, len(sec.GetNewLockPIN())),
+			checkLimit("MaxPinSize", limits.MaxPinSize, len(sec.GetNewErasePIN())))
+	}
+
+	// Firmware is sent as value of SETUP message, it's not limited by MaxValueSize.
+	if cmd.GetBody().GetSetup().GetFirmwareDownload() == false {
+		checks = append(checks, checkLimit("MaxValueSize", limits.MaxValueSize, len(value)))
+	}
+
+	checks = append(checks,
+		checkLimit("MaxPinSize", limits.MaxPinSize, len(msg.GetPinAuth().GetPin())),
+		checkLimit("MaxMessageSize", limits.MaxMessageSize, proto.Size(cmd)+requestHeaderAllowance))
+
+	for _, err := range checks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBatchCount checks batch operation count against kinetic device MaxOperationCountPerBatch.
+func validateBatchCount(limits *LimitsLog, count int) error {
+	if limits == nil {
+		return nil
+	}
+	return checkLimit("MaxOperationCountPerBatch", limits.MaxOperationCountPerBatch, count)
+}
+
+// responseLimits returns maximum message and value size of response message, from ClientOptions or device
+// limits with slack. Response message of GETKEYRANGE may carry MaxKeyRangeCount keys of MaxKeySize.
+func responseLimits(op ClientOptions, limits *LimitsLog) codec.Limits {
+	l := codec.Limits{MaxMessageSize: defaultMaxResponseMessageSize, MaxValueSize: defaultMaxResponseValueSize}
+	if limits != nil && limits.MaxMessageSize > 0 {
+		size := uint64(limits.MaxMessageSize)
+		if keys := uint64(limits.MaxKeyRangeCount) * uint64(limits.MaxKeySize); keys > size {
+			size = keys
+		}
+		l.MaxMessageSize = clampUint32(size + responseSlack)
+	}
+	if limits != nil && limits.MaxValueSize > 0 {
+		l.MaxValueSize = clampUint32(uint64(limits.MaxValueSize) + responseSlack)
+	}
+	if op.MaxResponseMessageSize > 0 {
+		l.MaxMessageSize = op.MaxResponseMessageSize
+	}
+	if op.MaxResponseValueSize > 0 {
+		l.MaxValueSize = op.MaxResponseValueSize
+	}
+	return l
+}
+
+func clampUint32(v uint64) uint32 {
+	if v > uint64(^uint32(0)) {
+		return ^uint32(0)
+	}
+	return uint32(v)
+}
@@ -0,0 +1,166 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Fields are structured context attached to log entry, eg. host, connection ID, sequence and message type.
+type Fields map[string]interface{}
+
+// Field names attached to log entries by kinetic library.
+const (
+	FieldHost         = "host"
+	FieldConnectionID = "connection_id"
+	FieldSequence     = "sequence"
+	FieldMessageType  = "message_type"
+	FieldStatus       = "status"
+	FieldError        = "error"
+)
+
+// Logger is the interface for kinetic library logging. It can be set package-wide by SetLogger,
+// or per connection by ClientOptions.Logger. Entries are logged only if Enabled returns true for
+// their level, so fields of filtered entries are not built.
+// Default Logger writes to logrus logger configured by SetLogLevel and SetLogOutput.
+type Logger interface {
+	Enabled(level LogLevel) bool
+	Log(level LogLevel, msg string, fields Fields)
+}
+
+// logrusLogger is the default Logger, writes to klog.
+type logrusLogger struct{}
+
+func (logrusLogger) Enabled(level LogLevel) bool {
+	return klog.Level >= logrus.Level(level)
+}
+
+func (logrusLogger) Log(level LogLevel, msg string, fields Fields) {
+	e := klog.WithFields(logrus.Fields(fields))
+	switch level {
+	case LogLevelDebug:
+		e.Debug(msg)
+	case LogLevelInfo:
+		e.Info(msg)
+	case LogLevelWarn:
+		e.Warn(msg)
+	default:
+		// Library never exits or panics from logging, caller handles the failure.
+		e.Error(msg)
+	}
+}
+
+var (
+	loggerMu      sync.RWMutex
+	packageLogger Logger = logrusLogger{}
+)
+
+// SetLogger sets package-wide Logger, for connections without ClientOptions.Logger. nil restores default Logger.
+func SetLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	if l == nil {
+		l = logrusLogger{}
+	}
+	packageLogger = l
+}
+
+func getLogger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return packageLogger
+}
+
+// contextLogger attaches fields to every entry, its own fields and fields of its parent.
+// Logger nil means package-wide Logger.
+type contextLogger struct {
+	logger Logger
+	parent *contextLogger
+	fields Fields
+}
+
+// newContextLogger returns contextLogger with fields, writes to l or package-wide Logger if l is nil.
+func newContextLogger(l Logger, fields Fields) *contextLogger {
+	return &contextLogger{logger: l, fields: fields}
+}
+
+// with returns contextLogger with more fields, writes to same Logger. Fields are merged only when logged.
+func (l *contextLogger) with(fields Fields) *contextLogger {
+	n := &contextLogger{parent: l, fields: fields}
+	if l != nil {
+		n.logger = l.logger
+	}
+	return n
+}
+
+// target returns the Logger entries are written to.
+func (l *contextLogger) target() Logger {
+	if l != nil && l.logger != nil {
+		return l.logger
+	}
+	return getLogger()
+}
+
+// enabled returns true if entries of level are logged.
+func (l *contextLogger) enabled(level LogLevel) bool {
+	return l.target().Enabled(level)
+}
+
+// addFields adds fields of l and its parents to all, fields of l override its parents.
+func (l *contextLogger) addFields(all Fields) {
+	if l == nil {
+		return
+	}
+	l.parent.addFields(all)
+	for k, v := range l.fields {
+		all[k] = v
+	}
+}
+
+func (l *contextLogger) log(level LogLevel, msg string, fields []Fields) {
+	logger := l.target()
+	if !logger.Enabled(level) {
+		return
+	}
+	all := Fields{}
+	l.addFields(all)
+	for _, f := range fields {
+		for k, v := range f {
+			all[k] = v
+		}
+	}
+	logger.Log(level, msg, all)
+}
+
+func (l *contextLogger) debug(msg string, fields ...Fields) {
+	l.log(LogLevelDebug, msg, fields)
+}
+
+func (l *contextLogger) info(msg string, fields ...Fields) {
+	l.log(LogLevelInfo, msg, fields)
+}
+
+func (l *contextLogger) warn(msg string, fields ...Fields) {
+	l.log(LogLevelWarn, msg, fields)
+}
+
+func (l *contextLogger) error(msg string, fields ...Fields) {
+	l.log(LogLevelError, msg, fields)
+}
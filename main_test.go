@@ -0,0 +1,46 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+func TestMain(m *testing.M) {
+	kinetic.SetLogLevel(kinetic.LogLevelDebug)
+
+	// Tests run against in-process simulator, unless KINETIC_DEVICE is set to use real kinetic device
+	// on 127.0.0.1, eg. KINETIC_DEVICE=1 go test
+	if os.Getenv("KINETIC_DEVICE") != "" {
+		os.Exit(kinetic.RunTests(m, "127.0.0.1", 8123, 8443))
+	}
+
+	sim, err := simulator.Start(simulator.Config{})
+	if err != nil {
+		fmt.Println("Can't start simulator", err)
+		os.Exit(-1)
+	}
+	code := kinetic.RunTests(m, sim.Host(), sim.Port(), sim.TLSPort())
+	sim.Close()
+	os.Exit(code)
+}
@@ -19,6 +19,7 @@ package kinetic
 
 import (
 	"bytes"
+	"context"
 	"sync"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
@@ -26,6 +27,12 @@ import (
 
 // NonBlockConnection send kinetic message to devices and doesn't wait for
 // response message from device.
+// Requests exceeding kinetic device limits from handshake LimitsLog are rejected with *LimitError,
+// without sending to device.
+// Outstanding requests are limited by device MaxOutstandingReadRequests and MaxOutstandingWriteRequests,
+// following ClientOptions.FlowControl. Callback shouldn't block waiting for another request's response.
+// NonBlockConnection is safe for concurrent use by multiple goroutines. Batch PUT / DELETE share the
+// current batch of the connection, started by BatchStart.
 type NonBlockConnection struct {
 	service    *networkService
 	batchID    uint32 // Current batch Operation ID
@@ -36,7 +43,8 @@ type NonBlockConnection struct {
 // NewNonBlockConnection is helper function to establish non-block connection to device.
 func NewNonBlockConnection(op ClientOptions) (*NonBlockConnection, error) {
 	if op.Hmac == nil {
-		klog.Panic("HMAC is required for ClientOptions")
+		newContextLogger(op.Logger, Fields{FieldHost: op.Host}).log(LogLevelPanic, "HMAC is required for ClientOptions", nil)
+		panic("HMAC is required for ClientOptions")
 	}
 
 	service, err := newNetworkService(op)
@@ -48,44 +56,63 @@ func NewNonBlockConnection(op ClientOptions) (*NonBlockConnection, error) {
 }
 
 // NoOp does nothing but wait for drive to return response.
-func (conn *NonBlockConnection) NoOp(h *ResponseHandler) error {
+func (conn *NonBlockConnection) NoOp(h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_NOOP)
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
-func (conn *NonBlockConnection) get(key []byte, getType kproto.Command_MessageType, h *ResponseHandler) error {
+func (conn *NonBlockConnection) get(key []byte, getType kproto.Command_MessageType, metaOnly bool, h *ResponseHandler, opts []RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(getType)
 	cmd.Body = &kproto.Command_Body{
 		KeyValue: &kproto.Command_KeyValue{
-			Key: key,
+			Key:          key,
+			MetadataOnly: &metaOnly,
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // Get gets the object from kinetic drive with key.
-func (conn *NonBlockConnection) Get(key []byte, h *ResponseHandler) error {
-	return conn.get(key, kproto.Command_GET, h)
+func (conn *NonBlockConnection) Get(key []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.get(key, kproto.Command_GET, false, h, opts)
 }
 
 // GetNext gets the next object with key after the passed in key.
-func (conn *NonBlockConnection) GetNext(key []byte, h *ResponseHandler) error {
-	return conn.get(key, kproto.Command_GETNEXT, h)
+func (conn *NonBlockConnection) GetNext(key []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.get(key, kproto.Command_GETNEXT, false, h, opts)
 }
 
 // GetPrevious gets the previous object with key before the passed in key.
-func (conn *NonBlockConnection) GetPrevious(key []byte, h *ResponseHandler) error {
-	return conn.get(key, kproto.Command_GETPREVIOUS, h)
+func (conn *NonBlockConnection) GetPrevious(key []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.get(key, kproto.Command_GETPREVIOUS, false, h, opts)
+}
+
+// GetMeta gets the object metadata (tag, algorithm and version) from kinetic drive with key.
+// Object value is not transferred.
+func (conn *NonBlockConnection) GetMeta(key []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.get(key, kproto.Command_GET, true, h, opts)
+}
+
+// GetNextMeta gets the metadata of next object with key after the passed in key.
+// Object value is not transferred.
+func (conn *NonBlockConnection) GetNextMeta(key []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.get(key, kproto.Command_GETNEXT, true, h, opts)
+}
+
+// GetPreviousMeta gets the metadata of previous object with key before the passed in key.
+// Object value is not transferred.
+func (conn *NonBlockConnection) GetPreviousMeta(key []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.get(key, kproto.Command_GETPREVIOUS, true, h, opts)
 }
 
 // GetKeyRange gets list of objects' keys, which meet the criteria defined by KeyRange.
-func (conn *NonBlockConnection) GetKeyRange(r *KeyRange, h *ResponseHandler) error {
+func (conn *NonBlockConnection) GetKeyRange(r *KeyRange, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_GETKEYRANGE)
@@ -100,11 +127,11 @@ func (conn *NonBlockConnection) GetKeyRange(r *KeyRange, h *ResponseHandler) err
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // GetVersion gets object DB version information.
-func (conn *NonBlockConnection) GetVersion(key []byte, h *ResponseHandler) error {
+func (conn *NonBlockConnection) GetVersion(key []byte, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_GETVERSION)
@@ -114,25 +141,26 @@ func (conn *NonBlockConnection) GetVersion(key []byte, h *ResponseHandler) error
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // Flush requests kinetic device to write all cached data to persistent media.
-func (conn *NonBlockConnection) Flush(h *ResponseHandler) error {
+func (conn *NonBlockConnection) Flush(h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_FLUSHALLDATA)
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
-func (conn *NonBlockConnection) delete(entry *Record, batch bool, h *ResponseHandler) error {
+func (conn *NonBlockConnection) delete(entry *Record, batch bool, h *ResponseHandler, opts []RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_DELETE)
 
-	// Bathc operation, batchID needed
+	// Bathc operation, batchID needed. batchMu is held by BatchPut / BatchDelete.
 	if batch {
-		cmd.Header.BatchID = &conn.batchID
+		batchID := conn.batchID
+		cmd.Header.BatchID = &batchID
 	}
 
 	sync := convertSyncToProto(entry.Sync)
@@ -140,28 +168,32 @@ func (conn *NonBlockConnection) delete(entry *Record, batch bool, h *ResponseHan
 	cmd.Body = &kproto.Command_Body{
 		KeyValue: &kproto.Command_KeyValue{
 			Key:             entry.Key,
+			DbVersion:       entry.Version,
 			Force:           &entry.Force,
 			Synchronization: &sync,
 			//Algorithm:       &algo,
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // Delete deletes object from kinetic device.
-func (conn *NonBlockConnection) Delete(entry *Record, h *ResponseHandler) error {
+// Unless entry.Force is true, object is only deleted if its version on kinetic device is entry.Version.
+// Use WriteCallback to get the version held by kinetic device on RemoteVersionMismatch.
+func (conn *NonBlockConnection) Delete(entry *Record, h *ResponseHandler, opts ...RequestOption) error {
 	// Normal DELETE operation, not batch operation.
-	return conn.delete(entry, false, h)
+	return conn.delete(entry, false, h, opts)
 }
 
-func (conn *NonBlockConnection) put(entry *Record, batch bool, h *ResponseHandler) error {
+func (conn *NonBlockConnection) put(entry *Record, batch bool, h *ResponseHandler, opts []RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_PUT)
 
-	// Bathc operation, batchID needed
+	// Bathc operation, batchID needed. batchMu is held by BatchPut / BatchDelete.
 	if batch {
-		cmd.Header.BatchID = &conn.batchID
+		batchID := conn.batchID
+		cmd.Header.BatchID = &batchID
 	}
 
 	sync := convertSyncToProto(entry.Sync)
@@ -169,6 +201,8 @@ func (conn *NonBlockConnection) put(entry *Record, batch bool, h *ResponseHandle
 	cmd.Body = &kproto.Command_Body{
 		KeyValue: &kproto.Command_KeyValue{
 			Key:             entry.Key,
+			DbVersion:       entry.Version,
+			NewVersion:      entry.NewVersion,
 			Force:           &entry.Force,
 			Synchronization: &sync,
 			Algorithm:       &algo,
@@ -176,13 +210,16 @@ func (conn *NonBlockConnection) put(entry *Record, batch bool, h *ResponseHandle
 		},
 	}
 
-	return conn.service.submit(msg, cmd, entry.Value, h)
+	return conn.service.submit(msg, cmd, entry.Value, h, opts)
 }
 
 // Put store object to kinetic device.
-func (conn *NonBlockConnection) Put(entry *Record, h *ResponseHandler) error {
+// Unless entry.Force is true, object is only stored if its version on kinetic device is entry.Version,
+// and entry.NewVersion becomes the new version of the object.
+// Use WriteCallback to get the version held by kinetic device on RemoteVersionMismatch.
+func (conn *NonBlockConnection) Put(entry *Record, h *ResponseHandler, opts ...RequestOption) error {
 	// Normal PUT operation, not batch operation
-	return conn.put(entry, false, h)
+	return conn.put(entry, false, h, opts)
 }
 
 func (conn *NonBlockConnection) buildP2PMessage(request *P2PPushRequest) *kproto.Command_P2POperation {
@@ -213,7 +250,7 @@ func (conn *NonBlockConnection) buildP2PMessage(request *P2PPushRequest) *kproto
 }
 
 // P2PPush performs peer to peer push operation
-func (conn *NonBlockConnection) P2PPush(request *P2PPushRequest, h *ResponseHandler) error {
+func (conn *NonBlockConnection) P2PPush(request *P2PPushRequest, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_PEER2PEERPUSH)
 
@@ -221,12 +258,12 @@ func (conn *NonBlockConnection) P2PPush(request *P2PPushRequest, h *ResponseHand
 		P2POperation: conn.buildP2PMessage(request),
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // BatchStart starts new batch operation, all following batch PUT / DELETE share same batch ID until
 // BatchEnd or BatchAbort is called.
-func (conn *NonBlockConnection) BatchStart(h *ResponseHandler) error {
+func (conn *NonBlockConnection) BatchStart(h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_START_BATCH)
 
@@ -234,57 +271,76 @@ func (conn *NonBlockConnection) BatchStart(h *ResponseHandler) error {
 	conn.batchMu.Lock()
 	conn.batchID++
 	conn.batchCount = 0 // Reset
+	batchID := conn.batchID
 	conn.batchMu.Unlock()
-	cmd.Header.BatchID = &conn.batchID
-	return conn.service.submit(msg, cmd, nil, h)
+	cmd.Header.BatchID = &batchID
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // BatchPut puts objects to kinetic drive, as a batch job. Batch PUT / DELETE won't expect acknowledgement
 // from kinetic device. Status for batch PUT / DELETE will only available in response message for BatchEnd.
-func (conn *NonBlockConnection) BatchPut(entry *Record) error {
+func (conn *NonBlockConnection) BatchPut(entry *Record, opts ...RequestOption) error {
 	// Batch operation PUT
 	conn.batchMu.Lock()
-	conn.batchCount++
-	conn.batchMu.Unlock()
-	return conn.put(entry, true, nil)
+	defer conn.batchMu.Unlock()
+	err := validateBatchCount(conn.service.deviceLog().Limits, int(conn.batchCount)+1)
+	if err == nil {
+		err = conn.put(entry, true, nil, opts)
+	}
+	if err == nil {
+		conn.batchCount++
+	}
+	return err
 }
 
 // BatchDelete delete object from kinetic drive, as a batch job. Batch PUT / DELETE won't expect acknowledgement
 // from kinetic device. Status for batch PUT / DELETE will only available in response message for BatchEnd.
-func (conn *NonBlockConnection) BatchDelete(entry *Record) error {
+func (conn *NonBlockConnection) BatchDelete(entry *Record, opts ...RequestOption) error {
 	// Batch operation DELETE
 	conn.batchMu.Lock()
-	conn.batchCount++
-	conn.batchMu.Unlock()
-	return conn.delete(entry, true, nil)
+	defer conn.batchMu.Unlock()
+	err := validateBatchCount(conn.service.deviceLog().Limits, int(conn.batchCount)+1)
+	if err == nil {
+		err = conn.delete(entry, true, nil, opts)
+	}
+	if err == nil {
+		conn.batchCount++
+	}
+	return err
 }
 
 // BatchEnd commits all batch jobs. Response from kinetic device will indicate succeeded jobs sequence number, or
 // the first failed job sequence number if there is a failure.
-func (conn *NonBlockConnection) BatchEnd(h *ResponseHandler) error {
+func (conn *NonBlockConnection) BatchEnd(h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_END_BATCH)
 
-	cmd.Header.BatchID = &conn.batchID
+	conn.batchMu.Lock()
+	batchID, batchCount := conn.batchID, conn.batchCount
+	conn.batchMu.Unlock()
+	cmd.Header.BatchID = &batchID
 	cmd.Body = &kproto.Command_Body{
 		Batch: &kproto.Command_Batch{
-			Count: &conn.batchCount,
+			Count: &batchCount,
 		},
 	}
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // BatchAbort aborts jobs in current batch operation.
-func (conn *NonBlockConnection) BatchAbort(h *ResponseHandler) error {
+func (conn *NonBlockConnection) BatchAbort(h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_ABORT_BATCH)
 
-	cmd.Header.BatchID = &conn.batchID
-	return conn.service.submit(msg, cmd, nil, h)
+	conn.batchMu.Lock()
+	batchID := conn.batchID
+	conn.batchMu.Unlock()
+	cmd.Header.BatchID = &batchID
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // GetLog gets kinetic device Log information. Can request single LogType or multiple LogType.
-func (conn *NonBlockConnection) GetLog(logs []LogType, h *ResponseHandler) error {
+func (conn *NonBlockConnection) GetLog(logs []LogType, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	types := make([]kproto.Command_GetLog_Type, len(logs))
@@ -298,10 +354,10 @@ func (conn *NonBlockConnection) GetLog(logs []LogType, h *ResponseHandler) error
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
-func (conn *NonBlockConnection) pinop(pin []byte, op kproto.Command_PinOperation_PinOpType, h *ResponseHandler) error {
+func (conn *NonBlockConnection) pinop(pin []byte, op kproto.Command_PinOperation_PinOpType, h *ResponseHandler, opts []RequestOption) error {
 	msg := newMessage(kproto.Message_PINAUTH)
 	msg.PinAuth = &kproto.Message_PINauth{
 		Pin: pin,
@@ -315,37 +371,37 @@ func (conn *NonBlockConnection) pinop(pin []byte, op kproto.Command_PinOperation
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // SecureErase request kinetic device to perform secure erase.
 // SSL connection is requested to perform this operation, and the erase pin is needed.
-func (conn *NonBlockConnection) SecureErase(pin []byte, h *ResponseHandler) error {
-	return conn.pinop(pin, kproto.Command_PinOperation_SECURE_ERASE_PINOP, h)
+func (conn *NonBlockConnection) SecureErase(pin []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.pinop(pin, kproto.Command_PinOperation_SECURE_ERASE_PINOP, h, opts)
 }
 
 // InstantErase request kinetic device to perform instant erase.
 // SSL connection is requested to perform this operation, and the erase pin is needed.
-func (conn *NonBlockConnection) InstantErase(pin []byte, h *ResponseHandler) error {
-	return conn.pinop(pin, kproto.Command_PinOperation_ERASE_PINOP, h)
+func (conn *NonBlockConnection) InstantErase(pin []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.pinop(pin, kproto.Command_PinOperation_ERASE_PINOP, h, opts)
 
 }
 
 // LockDevice locks the kinetic device.
 // SSL connection is requested to perform this operation, and the lock pin is needed.
-func (conn *NonBlockConnection) LockDevice(pin []byte, h *ResponseHandler) error {
-	return conn.pinop(pin, kproto.Command_PinOperation_LOCK_PINOP, h)
+func (conn *NonBlockConnection) LockDevice(pin []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.pinop(pin, kproto.Command_PinOperation_LOCK_PINOP, h, opts)
 }
 
 // UnlockDevice unlocks the kinetic device.
 // SSL connection is requested to perform this operation, and the lock pin is needed.
-func (conn *NonBlockConnection) UnlockDevice(pin []byte, h *ResponseHandler) error {
-	return conn.pinop(pin, kproto.Command_PinOperation_UNLOCK_PINOP, h)
+func (conn *NonBlockConnection) UnlockDevice(pin []byte, h *ResponseHandler, opts ...RequestOption) error {
+	return conn.pinop(pin, kproto.Command_PinOperation_UNLOCK_PINOP, h, opts)
 }
 
 // UpdateFirmware requests to update kientic device firmware.
 // Then drive will reboot and perform the firmware update process.
-func (conn *NonBlockConnection) UpdateFirmware(code []byte, h *ResponseHandler) error {
+func (conn *NonBlockConnection) UpdateFirmware(code []byte, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_SETUP)
 
@@ -356,11 +412,11 @@ func (conn *NonBlockConnection) UpdateFirmware(code []byte, h *ResponseHandler)
 		},
 	}
 
-	return conn.service.submit(msg, cmd, code, h)
+	return conn.service.submit(msg, cmd, code, h, opts)
 }
 
 // SetClusterVersion sets the cluster version on kinetic drive.
-func (conn *NonBlockConnection) SetClusterVersion(version int64, h *ResponseHandler) error {
+func (conn *NonBlockConnection) SetClusterVersion(version int64, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_SETUP)
 
@@ -370,17 +426,42 @@ func (conn *NonBlockConnection) SetClusterVersion(version int64, h *ResponseHand
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // SetClientClusterVersion sets the cluster version for all following message to kinetic device.
 func (conn *NonBlockConnection) SetClientClusterVersion(version int64) {
-	conn.service.clusterVersion = version
+	conn.service.setClusterVersion(version)
+}
+
+// ClientClusterVersion returns the cluster version for all following message to kinetic device.
+// It's the cluster version from handshake, unless changed by SetClientClusterVersion.
+func (conn *NonBlockConnection) ClientClusterVersion() int64 {
+	return conn.service.getClusterVersion()
+}
+
+// DeviceLog returns kinetic device Configuration and Limits received in handshake when connection established.
+// Log is updated if connection reconnected to device.
+func (conn *NonBlockConnection) DeviceLog() Log {
+	return conn.service.deviceLog()
+}
+
+// ConnectionID returns the connection ID assigned by kinetic device in handshake.
+func (conn *NonBlockConnection) ConnectionID() int64 {
+	return conn.service.connectionID()
+}
+
+// SubscribeUnsolicitedStatus registers f to receive unsolicited status messages from kinetic device,
+// returns the function to unsubscribe. For terminal status (RemoteConnectionTerminated, RemoteHibernate,
+// RemoteShutdown), connection is closed and all outstanding requests fail with the status, unless
+// ClientOptions.Reconnect is set, then connection reconnects as on network failure.
+func (conn *NonBlockConnection) SubscribeUnsolicitedStatus(f UnsolicitedStatusFunc) (unsubscribe func()) {
+	return conn.service.subscribe(f)
 }
 
 // SetLockPin changes kinetic device lock pin. Both current pin and new pin needed.
 // SSL connection is required to perform this operation.
-func (conn *NonBlockConnection) SetLockPin(currentPin []byte, newPin []byte, h *ResponseHandler) error {
+func (conn *NonBlockConnection) SetLockPin(currentPin []byte, newPin []byte, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_SECURITY)
 
@@ -391,12 +472,12 @@ func (conn *NonBlockConnection) SetLockPin(currentPin []byte, newPin []byte, h *
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // SetErasePin changes kinetic device erase pin. Both current pin and new pin needed.
 // SSL connection is required to perform this operation.
-func (conn *NonBlockConnection) SetErasePin(currentPin []byte, newPin []byte, h *ResponseHandler) error {
+func (conn *NonBlockConnection) SetErasePin(currentPin []byte, newPin []byte, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_SECURITY)
 
@@ -407,11 +488,11 @@ func (conn *NonBlockConnection) SetErasePin(currentPin []byte, newPin []byte, h
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // SetACL sets Permission for particular user Identity.
-func (conn *NonBlockConnection) SetACL(acls []ACL, h *ResponseHandler) error {
+func (conn *NonBlockConnection) SetACL(acls []ACL, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_SECURITY)
 
@@ -447,13 +528,13 @@ func (conn *NonBlockConnection) SetACL(acls []ACL, h *ResponseHandler) error {
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // MediaScan is to check that the user data is readable, and
 // if the end to end integrity is known to the device, if the
 // end to end integrity field is correct.
-func (conn *NonBlockConnection) MediaScan(op *MediaOperation, pri Priority, h *ResponseHandler) error {
+func (conn *NonBlockConnection) MediaScan(op *MediaOperation, pri Priority, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_MEDIASCAN)
@@ -470,13 +551,13 @@ func (conn *NonBlockConnection) MediaScan(op *MediaOperation, pri Priority, h *R
 	p := convertPriorityToProto(pri)
 	cmd.Header.Priority = &p
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // MediaOptimize performs optimizations of the media. Things like
 // defragmentation, compaction, garbage collection, compression
 // could be things accomplished using the media optimize command.
-func (conn *NonBlockConnection) MediaOptimize(op *MediaOperation, pri Priority, h *ResponseHandler) error {
+func (conn *NonBlockConnection) MediaOptimize(op *MediaOperation, pri Priority, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_MEDIAOPTIMIZE)
@@ -493,11 +574,11 @@ func (conn *NonBlockConnection) MediaOptimize(op *MediaOperation, pri Priority,
 	p := convertPriorityToProto(pri)
 	cmd.Header.Priority = &p
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
 // SetPowerLevel sets device power level
-func (conn *NonBlockConnection) SetPowerLevel(p PowerLevel, h *ResponseHandler) error {
+func (conn *NonBlockConnection) SetPowerLevel(p PowerLevel, h *ResponseHandler, opts ...RequestOption) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_SET_POWER_LEVEL)
@@ -510,18 +591,26 @@ func (conn *NonBlockConnection) SetPowerLevel(p PowerLevel, h *ResponseHandler)
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submit(msg, cmd, nil, h, opts)
 }
 
-// Listen waits and read response message from device, then call ResponseHandler
-// in queue to process received message.
+// Listen waits until response message for ResponseHandler received from device and processed.
+// Response messages are read and dispatched to their ResponseHandler by background goroutine,
+// so Listen can be called in any order, from any goroutine.
+// Error returned if response message can't be received, eg. network failure or connection closed.
 func (conn *NonBlockConnection) Listen(h *ResponseHandler) error {
-	err := conn.service.listen()
-	h.wait()
-	return err
+	return h.wait()
 }
 
-// Close the connection to kientic device
+// Close the connection to kientic device immediately. All outstanding ResponseHandler fail with ClientShutdown.
+// Requests submitted after Close fail with ErrConnectionClosed.
 func (conn *NonBlockConnection) Close() {
 	conn.service.close()
 }
+
+// Shutdown closes the connection to kinetic device gracefully. No new request is accepted, and Shutdown waits
+// for responses of outstanding requests until ctx is done. Then connection is closed, and requests still
+// outstanding fail with ClientShutdown. Returns ctx.Err() if ctx is done before all responses received.
+func (conn *NonBlockConnection) Shutdown(ctx context.Context) error {
+	return conn.service.shutdown(ctx)
+}
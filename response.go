@@ -0,0 +1,101 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// Response is the response message from kinetic device, decoded into library types.
+// Only the fields for the message type are set, eg. Record for GET, Keys for GETKEYRANGE.
+type Response struct {
+	MessageType MessageType    // Message type of the request, eg. MessageGet
+	Sequence    int64          // Sequence of the request, -1 if request not sent
+	Status      Status         // Status of the request
+	Record      *Record        // Object for GET, GETNEXT, GETPREVIOUS and GETVERSION, and PUT / DELETE version conflict
+	Keys        [][]byte       // Objects' keys for GETKEYRANGE
+	Log         *Log           // Device log for GETLOG
+	BatchStatus *BatchStatus   // Batch status for END_BATCH
+	P2PStatus   *P2PPushStatus // Peer to peer push status for PEER2PEERPUSH
+	Value       []byte         // Value bytes of response message
+}
+
+// ResponseCallback is the interface define actions for response message, it receives Response decoded
+// into library types, without depending on generated protobuf types.
+// Success is called when response message status code is OK.
+// Failure is called when response message status code is not OK, or request failed on client side.
+// Use Callback instead for access to raw protobuf messages.
+type ResponseCallback interface {
+	Success(resp *Response)
+	Failure(resp *Response)
+}
+
+// responseCallback adapts ResponseCallback to Callback.
+type responseCallback struct {
+	GenericCallback
+	c ResponseCallback
+	h *ResponseHandler
+}
+
+func (c *responseCallback) response(cmd *kproto.Command, value []byte, status Status) *Response {
+	resp := &Response{
+		MessageType: c.h.msgType,
+		Sequence:    c.h.seq,
+		Status:      status,
+		Value:       value,
+	}
+	body := cmd.GetBody()
+	if body.GetKeyValue() != nil {
+		resp.Record = getRecordFromProto(cmd, value)
+	}
+	if body.GetRange() != nil {
+		resp.Keys = body.GetRange().GetKeys()
+	}
+	if body.GetGetLog() != nil {
+		log := getLogFromProto(cmd)
+		resp.Log = &log
+	}
+	if body.GetBatch() != nil && c.h.msgType == MessageEndBatch {
+		resp.BatchStatus = getBatchStatusFromProto(cmd)
+	}
+	if body.GetP2POperation() != nil {
+		resp.P2PStatus = getP2PPushStatusFromProto(cmd)
+	}
+	return resp
+}
+
+// Success decodes response message and calls ResponseCallback.Success.
+func (c *responseCallback) Success(cmd *kproto.Command, value []byte) {
+	c.GenericCallback.Success(cmd, value)
+	c.c.Success(c.response(cmd, value, c.Status()))
+}
+
+// Failure decodes response message if any and calls ResponseCallback.Failure.
+func (c *responseCallback) Failure(cmd *kproto.Command, status Status) {
+	c.GenericCallback.Failure(cmd, status)
+	c.c.Failure(c.response(cmd, nil, status))
+}
+
+// NewResponseCallbackHandler is helper function to build a ResponseHandler with ResponseCallback.
+// For each operation, a unique ResponseHandler is required
+func NewResponseCallbackHandler(call ResponseCallback) *ResponseHandler {
+	c := &responseCallback{c: call}
+	h := NewResponseHandler(c)
+	c.h = h
+	return h
+}
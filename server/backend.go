@@ -0,0 +1,101 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+)
+
+// Backend stores objects for Server. Requests are authenticated and authorized by Server before
+// dispatched to Backend, Backend only deals with storage.
+//
+// Methods return nil on success. To fail the request with a specific status, return kinetic.StatusCode,
+// kinetic.Status or an error wrapping them, eg. kinetic.RemoteNotFound or kinetic.RemoteVersionMismatch.
+// Other errors fail the request with INTERNAL_ERROR. Backend methods may be called concurrently for
+// requests from different connections.
+type Backend interface {
+	// Get returns the object of key, Record.Value may be nil if metaOnly.
+	Get(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error)
+	// GetNext returns the object with the smallest key greater than key.
+	GetNext(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error)
+	// GetPrevious returns the object with the largest key less than key.
+	GetPrevious(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error)
+	// GetKeyRange returns keys within r, at most r.Max keys.
+	GetKeyRange(ctx context.Context, r *kinetic.KeyRange) ([][]byte, error)
+	// Put stores the object with version r.NewVersion. Unless r.Force, r.Version must match
+	// version of the stored object, or be empty if object doesn't exist.
+	Put(ctx context.Context, r *kinetic.Record) error
+	// Delete removes the object. Unless r.Force, r.Version must match version of the stored object.
+	Delete(ctx context.Context, r *kinetic.Record) error
+	// Batch applies all operations atomically, in order. If an operation fails, none is applied
+	// and *BatchError identifies the failed operation.
+	Batch(ctx context.Context, ops []BatchOp) error
+	// GetLog returns the logs of types. Configuration and Limits left nil are filled by Server.
+	GetLog(ctx context.Context, types []kinetic.LogType) (*kinetic.Log, error)
+	// Setup applies setup request. Server changes its cluster version after Setup succeeds.
+	Setup(ctx context.Context, s *Setup) error
+}
+
+// Eraser is implemented by Backend which supports erase PIN operations. Without it, erase requests fail
+// with INVALID_REQUEST.
+type Eraser interface {
+	// Erase removes all objects, secure is true for SECURE_ERASE.
+	Erase(ctx context.Context, secure bool) error
+}
+
+// BatchOp is one PUT or DELETE operation in batch.
+type BatchOp struct {
+	Type     kinetic.MessageType // MessagePut or MessageDelete
+	Sequence int64               // Sequence of the operation request
+	Record   *kinetic.Record
+}
+
+// BatchError is returned by Backend.Batch when an operation of batch fails.
+type BatchError struct {
+	Sequence int64 // Sequence of the failed operation, BatchOp.Sequence
+	Err      error // Failure of the operation
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation sequence %d failed, %s", e.Sequence, e.Err.Error())
+}
+
+// Unwrap returns failure of the operation.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Setup is the SETUP request.
+type Setup struct {
+	NewClusterVersion *int64 // New cluster version, nil if unchanged
+	FirmwareDownload  bool   // Firmware is included in request
+	Firmware          []byte // Firmware image, if FirmwareDownload
+}
+
+type contextKey int
+
+const identityKey contextKey = 0
+
+// IdentityFromContext returns the authenticated identity of the request, for Backend methods.
+func IdentityFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(identityKey).(int64)
+	return id, ok
+}
@@ -0,0 +1,305 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+/*
+Package server implements kinetic device endpoints over pluggable storage.
+
+Server accepts connections, does the handshake, authenticates HMAC identities against ACLs,
+enforces ACL permissions, cluster version, device lock and power level, and dispatches each request
+to Backend:
+
+	srv := server.New(backend, server.Config{
+		ACLs: []kinetic.ACL{{
+			Identity: 1,
+			Key:      []byte("asdfasdf"),
+			Scopes:   []kinetic.ACLScope{{Permissions: server.AllPermissions}},
+		}},
+	})
+	ln, err := net.Listen("tcp", ":8123")
+	if err != nil {
+		panic(err)
+	}
+	srv.Serve(ln)
+
+For TLS, serve a listener from tls.NewListener. SECURITY and PIN operations are only accepted on TLS
+connections, PIN erase requires Backend implementing Eraser. P2P requests are not supported, and fail
+with INVALID_REQUEST.
+*/
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/internal/convert"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// AllPermissions are all ACL permissions known to Server.
+var AllPermissions = []kinetic.ACLPermission{
+	kinetic.ACLPermissionRead,
+	kinetic.ACLPermissionWrite,
+	kinetic.ACLPermissionDelete,
+	kinetic.ACLPermissionRange,
+	kinetic.ACLPermissionSetup,
+	kinetic.ACLPermissionP2POP,
+	kinetic.ACLPermissionGetLog,
+	kinetic.ACLPermissionSecurity,
+	kinetic.ACLPermissionPowerManagement,
+}
+
+// DefaultLimits are reported in handshake and enforced if Config.Limits is nil.
+var DefaultLimits = kinetic.LimitsLog{
+	MaxKeySize:                  4096,
+	MaxValueSize:                1024 * 1024,
+	MaxVersionSize:              2048,
+	MaxTagSize:                  128,
+	MaxConnections:              100,
+	MaxOutstandingReadRequests:  1000,
+	MaxOutstandingWriteRequests: 1000,
+	MaxMessageSize:              1024 * 1024,
+	MaxKeyRangeCount:            200,
+	MaxIdentityCount:            100,
+	MaxPinSize:                  1024,
+	MaxOperationCountPerBatch:   100,
+	MaxBatchCountPerDevice:      5,
+}
+
+// Values larger than MaxValueSize are still read and rejected with INVALID_REQUEST, and firmware
+// of SETUP is not limited by MaxValueSize. The connection is only dropped for values above this size.
+const maxFrameValueSize = 64 * 1024 * 1024
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("Kinetic server closed")
+
+// Config defines identities, limits and device information of Server.
+type Config struct {
+	ACLs           []kinetic.ACL             // Identities allowed to connect, and their permissions
+	ClusterVersion int64                     // Initial cluster version
+	LockPin        []byte                    // Initial lock PIN, device can't be locked if empty
+	ErasePin       []byte                    // Initial erase PIN
+	Limits         *kinetic.LimitsLog        // Limits reported and enforced, default is DefaultLimits
+	Configuration  *kinetic.ConfigurationLog // Device configuration reported in handshake and GETLOG
+
+	// OnRequest is called before each request is processed, with its message type and value size,
+	// eg. to collect statistics or delay responses. It may be called concurrently.
+	OnRequest func(t kinetic.MessageType, valueSize int)
+}
+
+// Server serves kinetic protocol connections with Backend.
+type Server struct {
+	backend   Backend
+	limits    kinetic.LimitsLog
+	conf      kinetic.ConfigurationLog
+	onRequest func(t kinetic.MessageType, valueSize int)
+
+	mu             sync.Mutex
+	acls           map[int64]*kinetic.ACL
+	clusterVersion int64
+	lockPin        []byte
+	erasePin       []byte
+	locked         bool
+	power          kinetic.PowerLevel
+	batches        int // Open batches of all connections
+	listeners      map[net.Listener]struct{}
+	sessions       map[*session]struct{}
+	nextID         int64
+	closed         bool
+	wg             sync.WaitGroup
+}
+
+// New creates Server dispatching requests to backend.
+func New(backend Backend, cfg Config) *Server {
+	s := &Server{
+		backend:        backend,
+		acls:           make(map[int64]*kinetic.ACL),
+		limits:         DefaultLimits,
+		onRequest:      cfg.OnRequest,
+		clusterVersion: cfg.ClusterVersion,
+		lockPin:        cfg.LockPin,
+		erasePin:       cfg.ErasePin,
+		power:          kinetic.PowerLevelOperational,
+		listeners:      make(map[net.Listener]struct{}),
+		sessions:       make(map[*session]struct{}),
+		nextID:         time.Now().Unix(),
+	}
+	for k := range cfg.ACLs {
+		acl := cfg.ACLs[k]
+		s.acls[acl.Identity] = &acl
+	}
+	if cfg.Limits != nil {
+		s.limits = *cfg.Limits
+	}
+	if cfg.Configuration != nil {
+		s.conf = *cfg.Configuration
+	} else {
+		s.conf = kinetic.ConfigurationLog{
+			Vendor:            "kinetic-go",
+			Model:             "server",
+			ProtocolVersion:   "3.1.0",
+			CurrentPowerLevel: kinetic.PowerLevelOperational,
+		}
+	}
+	return s
+}
+
+// Serve accepts connections on ln and serves them, until ln fails or Server closed. Connections above
+// Limits.MaxConnections are closed after unsolicited status CONNECTION_TERMINATED.
+// Serve always returns non-nil error, ErrServerClosed after Close.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+	}()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return ErrServerClosed
+		}
+		s.nextID++
+		ss := newSession(s, c, s.nextID)
+		s.wg.Add(1)
+		if s.limits.MaxConnections > 0 && uint32(len(s.sessions)) >= s.limits.MaxConnections {
+			s.mu.Unlock()
+			go func() {
+				defer s.wg.Done()
+				ss.reject(kproto.Command_Status_CONNECTION_TERMINATED, "Too many connections")
+			}()
+			continue
+		}
+		s.sessions[ss] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			ss.serve()
+			s.mu.Lock()
+			delete(s.sessions, ss)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops all listeners and closes all connections, waits for requests in process to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	for ss := range s.sessions {
+		ss.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// Notify sends unsolicited status to all connected clients. For terminal status RemoteConnectionTerminated,
+// RemoteHibernate and RemoteShutdown, connections are closed afterwards.
+func (s *Server) Notify(code kinetic.StatusCode, msg string) {
+	for _, ss := range s.connected() {
+		ss.unsolicited(convert.StatusCodeToProto(code), msg)
+		switch code {
+		case kinetic.RemoteConnectionTerminated, kinetic.RemoteHibernate, kinetic.RemoteShutdown:
+			ss.conn.Close()
+		}
+	}
+}
+
+// Disconnect closes all client connections without notification, Server keeps serving.
+func (s *Server) Disconnect() {
+	for _, ss := range s.connected() {
+		ss.conn.Close()
+	}
+}
+
+func (s *Server) connected() []*session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for ss := range s.sessions {
+		sessions = append(sessions, ss)
+	}
+	return sessions
+}
+
+// ClusterVersion returns the current cluster version.
+func (s *Server) ClusterVersion() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clusterVersion
+}
+
+// PowerLevel returns the current power level, set by SET_POWER_LEVEL request.
+func (s *Server) PowerLevel() kinetic.PowerLevel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.power
+}
+
+// configuration returns device configuration with current power level.
+func (s *Server) configuration() *kinetic.ConfigurationLog {
+	conf := s.conf
+	conf.CurrentPowerLevel = s.PowerLevel()
+	return &conf
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// maxFrameValueSize returns the largest value read from connection.
+func (s *Server) maxFrameValueSize() uint32 {
+	if s.limits.MaxValueSize > maxFrameValueSize {
+		return s.limits.MaxValueSize
+	}
+	return maxFrameValueSize
+}
+
+func (s *Server) acl(identity int64) (*kinetic.ACL, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acl, ok := s.acls[identity]
+	return acl, ok
+}
@@ -0,0 +1,438 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/codec"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	"github.com/Kinetic/kinetic-go/server"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// memoryBackend keeps objects in memory, for test.
+type memoryBackend struct {
+	mu      sync.Mutex
+	objects map[string]kinetic.Record
+	setups  []*server.Setup
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{objects: make(map[string]kinetic.Record)}
+}
+
+func (b *memoryBackend) sortedKeys() []string {
+	keys := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (b *memoryBackend) record(key string, metaOnly bool) (*kinetic.Record, error) {
+	r, ok := b.objects[key]
+	if !ok {
+		return nil, kinetic.RemoteNotFound
+	}
+	if metaOnly {
+		r.Value = nil
+	}
+	return &r, nil
+}
+
+func (b *memoryBackend) Get(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.record(string(key), metaOnly)
+}
+
+func (b *memoryBackend) GetNext(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, k := range b.sortedKeys() {
+		if k > string(key) {
+			return b.record(k, metaOnly)
+		}
+	}
+	return nil, kinetic.RemoteNotFound
+}
+
+func (b *memoryBackend) GetPrevious(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := b.sortedKeys()
+	for k := len(keys) - 1; k >= 0; k-- {
+		if keys[k] < string(key) {
+			return b.record(keys[k], metaOnly)
+		}
+	}
+	return nil, kinetic.RemoteNotFound
+}
+
+func (b *memoryBackend) GetKeyRange(ctx context.Context, r *kinetic.KeyRange) ([][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([][]byte, 0)
+	for _, k := range b.sortedKeys() {
+		if int32(len(keys)) >= r.Max {
+			break
+		}
+		if c := bytes.Compare([]byte(k), r.StartKey); c < 0 || (c == 0 && !r.StartKeyInclusive) {
+			continue
+		}
+		if c := bytes.Compare([]byte(k), r.EndKey); c > 0 || (c == 0 && !r.EndKeyInclusive) {
+			continue
+		}
+		keys = append(keys, []byte(k))
+	}
+	return keys, nil
+}
+
+func (b *memoryBackend) check(r *kinetic.Record) error {
+	cur, ok := b.objects[string(r.Key)]
+	if !r.Force && ok && !bytes.Equal(cur.Version, r.Version) {
+		return kinetic.RemoteVersionMismatch
+	}
+	return nil
+}
+
+func (b *memoryBackend) Put(ctx context.Context, r *kinetic.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.check(r); err != nil {
+		return err
+	}
+	b.objects[string(r.Key)] = kinetic.Record{Key: r.Key, Value: r.Value, Version: r.NewVersion, Tag: r.Tag, Algo: r.Algo}
+	return nil
+}
+
+func (b *memoryBackend) Delete(ctx context.Context, r *kinetic.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.objects[string(r.Key)]; !ok {
+		return kinetic.RemoteNotFound
+	}
+	if err := b.check(r); err != nil {
+		return err
+	}
+	delete(b.objects, string(r.Key))
+	return nil
+}
+
+func (b *memoryBackend) Batch(ctx context.Context, ops []server.BatchOp) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, op := range ops {
+		if err := b.check(op.Record); err != nil {
+			return &server.BatchError{Sequence: op.Sequence, Err: err}
+		}
+	}
+	for _, op := range ops {
+		if op.Type == kinetic.MessageDelete {
+			delete(b.objects, string(op.Record.Key))
+		} else {
+			b.objects[string(op.Record.Key)] = kinetic.Record{Key: op.Record.Key, Value: op.Record.Value, Version: op.Record.NewVersion}
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) GetLog(ctx context.Context, types []kinetic.LogType) (*kinetic.Log, error) {
+	return &kinetic.Log{Capacity: &kinetic.CapacityLog{CapacityInBytes: 1 << 30}}, nil
+}
+
+func (b *memoryBackend) Setup(ctx context.Context, s *server.Setup) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id, ok := server.IdentityFromContext(ctx); !ok || id != 1 {
+		return errors.New("identity not in context")
+	}
+	b.setups = append(b.setups, s)
+	return nil
+}
+
+var readOnly = []kinetic.ACLPermission{kinetic.ACLPermissionRead, kinetic.ACLPermissionRange}
+
+func startServer(t *testing.T, backend server.Backend) kinetic.ClientOptions {
+	return startServerWithLimits(t, backend, nil)
+}
+
+// startServerWithLimits starts Server as startServer, which enforces limits.
+func startServerWithLimits(t *testing.T, backend server.Backend, limits *kinetic.LimitsLog) kinetic.ClientOptions {
+	srv := server.New(backend, server.Config{
+		ACLs: []kinetic.ACL{
+			{Identity: 1, Key: []byte("asdfasdf"), Scopes: []kinetic.ACLScope{{Permissions: server.AllPermissions}}},
+			{Identity: 2, Key: []byte("reader"), Scopes: []kinetic.ACLScope{{Value: []byte("public"), Permissions: readOnly}}},
+		},
+		Limits: limits,
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen Failure", err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	return kinetic.ClientOptions{
+		Host: "127.0.0.1",
+		Port: ln.Addr().(*net.TCPAddr).Port,
+		User: 1,
+		Hmac: []byte("asdfasdf"),
+	}
+}
+
+func connect(t *testing.T, op kinetic.ClientOptions) *kinetic.BlockConnection {
+	conn, err := kinetic.NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServerKeyValue(t *testing.T) {
+	conn := connect(t, startServer(t, newMemoryBackend()))
+
+	if conn.DeviceLog().Limits.MaxKeySize != server.DefaultLimits.MaxKeySize {
+		t.Fatal("Handshake without limits", conn.DeviceLog().Limits)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		entry := kinetic.Record{Key: []byte(key), Value: []byte("value-" + key), NewVersion: []byte("v1"),
+			Sync: kinetic.SyncWriteThrough, Algo: kinetic.AlgorithmSHA1}
+		if status, err := conn.Put(&entry); err != nil || status.Code != kinetic.OK {
+			t.Fatal("Put Failure", err, status.String())
+		}
+	}
+
+	record, status, err := conn.Get([]byte("b"))
+	if err != nil || !bytes.Equal(record.Value, []byte("value-b")) || !bytes.Equal(record.Version, []byte("v1")) {
+		t.Fatal("Get Failure", err, status.String())
+	}
+	if record, _, err := conn.GetNext([]byte("b")); err != nil || string(record.Key) != "c" {
+		t.Fatal("GetNext Failure", err)
+	}
+	if record, _, err := conn.GetPreviousMeta([]byte("b")); err != nil || string(record.Key) != "a" || record.Value != nil {
+		t.Fatal("GetPreviousMeta Failure", err)
+	}
+	if version, _, err := conn.GetVersion([]byte("a")); err != nil || !bytes.Equal(version, []byte("v1")) {
+		t.Fatal("GetVersion Failure", err)
+	}
+	if _, status, _ := conn.Get([]byte("x")); status.Code != kinetic.RemoteNotFound {
+		t.Fatal("Get missing key expect RemoteNotFound", status.String())
+	}
+
+	keys, _, err := conn.GetKeyRange(&kinetic.KeyRange{StartKey: []byte("a"), EndKey: []byte("c"), StartKeyInclusive: true, Max: 10})
+	if err != nil || len(keys) != 2 {
+		t.Fatal("GetKeyRange Failure", err, len(keys))
+	}
+
+	// Version mismatch status from Backend
+	entry := kinetic.Record{Key: []byte("a"), Version: []byte("v0"), Sync: kinetic.SyncWriteThrough}
+	if status, _ := conn.Delete(&entry); status.Code != kinetic.RemoteVersionMismatch {
+		t.Fatal("Delete with wrong version expect RemoteVersionMismatch", status.String())
+	}
+	entry.Version = []byte("v1")
+	if status, err := conn.Delete(&entry); err != nil || status.Code != kinetic.OK {
+		t.Fatal("Delete Failure", err, status.String())
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	backend := newMemoryBackend()
+	conn := connect(t, startServer(t, backend))
+
+	if status, err := conn.BatchStart(); err != nil || status.Code != kinetic.OK {
+		t.Fatal("BatchStart Failure", err, status.String())
+	}
+	conn.BatchPut(&kinetic.Record{Key: []byte("k1"), Value: []byte("v"), Force: true, Sync: kinetic.SyncWriteThrough})
+	conn.BatchPut(&kinetic.Record{Key: []byte("k2"), Value: []byte("v"), Force: true, Sync: kinetic.SyncWriteThrough})
+	bs, status, err := conn.BatchEnd()
+	if err != nil || status.Code != kinetic.OK || len(bs.DoneSequence) != 2 {
+		t.Fatal("BatchEnd Failure", err, status.String())
+	}
+	if len(backend.objects) != 2 {
+		t.Fatal("Batch not applied", backend.objects)
+	}
+}
+
+func TestServerAuthorization(t *testing.T) {
+	backend := newMemoryBackend()
+	backend.objects["public/a"] = kinetic.Record{Key: []byte("public/a"), Value: []byte("a")}
+	backend.objects["private/a"] = kinetic.Record{Key: []byte("private/a"), Value: []byte("a")}
+	op := startServer(t, backend)
+
+	reader := op
+	reader.User, reader.Hmac = 2, []byte("reader")
+	conn := connect(t, reader)
+	if _, status, err := conn.Get([]byte("public/a")); err != nil || status.Code != kinetic.OK {
+		t.Fatal("Get in scope Failure", err, status.String())
+	}
+	if _, status, _ := conn.Get([]byte("private/a")); status.Code != kinetic.RemoteNotAuthorized {
+		t.Fatal("Get out of scope expect RemoteNotAuthorized", status.String())
+	}
+	if status, _ := conn.Put(&kinetic.Record{Key: []byte("public/b"), Force: true, Sync: kinetic.SyncWriteThrough}); status.Code != kinetic.RemoteNotAuthorized {
+		t.Fatal("Put without permission expect RemoteNotAuthorized", status.String())
+	}
+
+	// Unknown identity is rejected
+	unknown := op
+	unknown.User = 3
+	conn = connect(t, unknown)
+	if status, _ := conn.NoOp(); status.Code == kinetic.OK {
+		t.Fatal("NoOp with unknown identity expect failure")
+	}
+}
+
+func TestServerSetupAndGetLog(t *testing.T) {
+	backend := newMemoryBackend()
+	conn := connect(t, startServer(t, backend))
+
+	if status, err := conn.SetClusterVersion(7); err != nil || status.Code != kinetic.OK {
+		t.Fatal("SetClusterVersion Failure", err, status.String())
+	}
+	if status, _ := conn.NoOp(); status.Code != kinetic.RemoteClusterVersionMismatch || status.ExpectedClusterVersion != 7 {
+		t.Fatal("NoOp with old cluster version expect RemoteClusterVersionMismatch", status.String())
+	}
+	conn.SetClientClusterVersion(7)
+
+	log, status, err := conn.GetLog([]kinetic.LogType{kinetic.LogTypeCapacities, kinetic.LogTypeConfiguration})
+	if err != nil || status.Code != kinetic.OK {
+		t.Fatal("GetLog Failure", err, status.String())
+	}
+	if log.Capacity == nil || log.Capacity.CapacityInBytes != 1<<30 || log.Configuration == nil || log.Configuration.Vendor == "" {
+		t.Fatal("GetLog expect capacity from Backend and configuration from Server", log)
+	}
+	if len(backend.setups) != 1 || *backend.setups[0].NewClusterVersion != 7 {
+		t.Fatal("Setup not dispatched to Backend")
+	}
+
+	// Firmware is not limited by MaxValueSize
+	firmware := make([]byte, 2*server.DefaultLimits.MaxValueSize)
+	if status, err := conn.UpdateFirmware(firmware); err != nil || status.Code != kinetic.OK {
+		t.Fatal("UpdateFirmware Failure", err, status.String())
+	}
+	if len(backend.setups) != 2 || !backend.setups[1].FirmwareDownload || len(backend.setups[1].Firmware) != len(firmware) {
+		t.Fatal("Firmware not dispatched to Backend")
+	}
+}
+
+func TestServerMaxConnections(t *testing.T) {
+	limits := server.DefaultLimits
+	limits.MaxConnections = 1
+	op := startServerWithLimits(t, newMemoryBackend(), &limits)
+
+	conn, err := kinetic.NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	if _, err := kinetic.NewBlockConnection(op); err == nil {
+		t.Fatal("Connection above MaxConnections expect failure")
+	}
+
+	// Slot is released when connection closes
+	conn.Close()
+	for k := 0; ; k++ {
+		conn, err = kinetic.NewBlockConnection(op)
+		if err == nil {
+			break
+		}
+		if k == 100 {
+			t.Fatal("Connection after close Failure", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.Close()
+}
+
+// sendRequest sends request cmd with codec after handshake, as client validates requests before sending.
+// Returns the response.
+func sendRequest(t *testing.T, op kinetic.ClientOptions, cmd *kproto.Command) *codec.Frame {
+	c, err := net.Dial("tcp", net.JoinHostPort(op.Host, strconv.Itoa(op.Port)))
+	if err != nil {
+		t.Fatal("Dial Failure", err)
+	}
+	defer c.Close()
+	rd := codec.NewReader(c)
+	handshake, err := rd.Read()
+	if err != nil {
+		t.Fatal("Handshake Failure", err)
+	}
+
+	cmd.Header.ConnectionID = handshake.Command.GetHeader().ConnectionID
+	cmd.Header.Sequence = proto.Int64(1)
+	w := codec.NewWriter(c)
+	w.Key = codec.StaticKey(op.User, op.Hmac)
+	err = w.Write(&codec.Frame{
+		Message: &kproto.Message{
+			AuthType: kproto.Message_HMACAUTH.Enum(),
+			HmacAuth: &kproto.Message_HMACauth{Identity: proto.Int64(op.User)},
+		},
+		Command: cmd,
+	})
+	if err != nil {
+		t.Fatal("Write Failure", err)
+	}
+	resp, err := rd.Read()
+	if err != nil {
+		t.Fatal("Read Failure", err)
+	}
+	return resp
+}
+
+func TestServerDeleteLimits(t *testing.T) {
+	op := startServer(t, newMemoryBackend())
+
+	resp := sendRequest(t, op, &kproto.Command{
+		Header: &kproto.Command_Header{MessageType: kproto.Command_DELETE.Enum()},
+		Body: &kproto.Command_Body{KeyValue: &kproto.Command_KeyValue{
+			Key:   make([]byte, server.DefaultLimits.MaxKeySize+1),
+			Force: proto.Bool(true),
+		}},
+	})
+	if code := resp.Command.GetStatus().GetCode(); code != kproto.Command_Status_INVALID_REQUEST {
+		t.Fatal("Delete with key too long expect INVALID_REQUEST", code)
+	}
+	if mt := resp.Command.GetHeader().GetMessageType(); mt != kproto.Command_DELETE_RESPONSE {
+		t.Fatal("Delete expect DELETE_RESPONSE", mt)
+	}
+}
+
+func TestServerUnknownMessageType(t *testing.T) {
+	op := startServer(t, newMemoryBackend())
+
+	for _, mt := range []kproto.Command_MessageType{kproto.Command_INVALID_MESSAGE_TYPE, 100} {
+		resp := sendRequest(t, op, &kproto.Command{Header: &kproto.Command_Header{MessageType: mt.Enum()}})
+		if code := resp.Command.GetStatus().GetCode(); code != kproto.Command_Status_INVALID_REQUEST {
+			t.Fatal("Unknown message type expect INVALID_REQUEST", mt, code)
+		}
+		if rt := resp.Command.GetHeader().GetMessageType(); rt != kproto.Command_INVALID_MESSAGE_TYPE {
+			t.Fatal("Unknown message type expect INVALID_MESSAGE_TYPE response", mt, rt)
+		}
+	}
+}
@@ -0,0 +1,756 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/codec"
+	"github.com/Kinetic/kinetic-go/internal/convert"
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// rejectTimeout limits time to notify rejected connection.
+const rejectTimeout = time.Second
+
+// session serves one client connection. Requests are processed in order they are received.
+type session struct {
+	srv     *Server
+	conn    net.Conn
+	connID  int64
+	tls     bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	batches map[uint32][]BatchOp
+	wmu     sync.Mutex // Serializes responses and unsolicited status from Server.Notify
+}
+
+func newSession(srv *Server, conn net.Conn, connID int64) *session {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, isTLS := conn.(*tls.Conn)
+	return &session{
+		srv:     srv,
+		conn:    conn,
+		connID:  connID,
+		tls:     isTLS,
+		ctx:     ctx,
+		cancel:  cancel,
+		batches: make(map[uint32][]BatchOp),
+	}
+}
+
+// request is the decoded request being processed.
+type request struct {
+	frame *codec.Frame
+	acl   *kinetic.ACL
+	ctx   context.Context
+}
+
+func (r *request) header() *kproto.Command_Header {
+	return r.frame.Command.GetHeader()
+}
+
+func (r *request) keyValue() *kproto.Command_KeyValue {
+	return r.frame.Command.GetBody().GetKeyValue()
+}
+
+// response is the result of request, sent back to client.
+type response struct {
+	code  kproto.Command_Status_StatusCode
+	msg   string
+	body  *kproto.Command_Body
+	value []byte
+}
+
+// fail returns response for err returned by Backend. Current version of *kinetic.VersionConflictError
+// is returned to client.
+func fail(err error) *response {
+	var conflict *kinetic.VersionConflictError
+	if errors.As(err, &conflict) {
+		resp := failCode(kinetic.RemoteVersionMismatch, conflict.Status.ErrorMsg)
+		resp.body = &kproto.Command_Body{KeyValue: &kproto.Command_KeyValue{Key: conflict.Key, DbVersion: conflict.CurrentVersion}}
+		return resp
+	}
+	var s kinetic.Status
+	if errors.As(err, &s) {
+		return failCode(s.Code, s.ErrorMsg)
+	}
+	var c kinetic.StatusCode
+	if errors.As(err, &c) {
+		return failCode(c, err.Error())
+	}
+	return &response{code: kproto.Command_Status_INTERNAL_ERROR, msg: err.Error()}
+}
+
+func failCode(c kinetic.StatusCode, msg string) *response {
+	code := convert.StatusCodeToProto(c)
+	if code == kproto.Command_Status_INVALID_STATUS_CODE {
+		// Client side status code, not meaningful to client
+		code = kproto.Command_Status_INTERNAL_ERROR
+	}
+	return &response{code: code, msg: msg}
+}
+
+func invalid(msg string) *response {
+	return &response{code: kproto.Command_Status_INVALID_REQUEST, msg: msg}
+}
+
+var success = &response{code: kproto.Command_Status_SUCCESS}
+
+// reply returns successful response with body.
+func reply(body *kproto.Command_Body) *response {
+	return &response{code: kproto.Command_Status_SUCCESS, body: body}
+}
+
+func (ss *session) serve() {
+	defer ss.cancel()
+	defer ss.conn.Close()
+	defer ss.abortBatches()
+
+	if err := ss.handshake(); err != nil {
+		return
+	}
+
+	rd := codec.NewReader(ss.conn)
+	rd.Limits = codec.Limits{MaxMessageSize: ss.srv.limits.MaxMessageSize, MaxValueSize: ss.srv.maxFrameValueSize()}
+	rd.Key = ss.key
+	for {
+		f, err := rd.Read()
+		if err != nil {
+			var sizeErr *codec.SizeError
+			switch {
+			case err == codec.ErrHMACMismatch || err == codec.ErrUnknownIdentity:
+				ss.unsolicited(kproto.Command_Status_HMAC_FAILURE, err.Error())
+			case errors.As(err, &sizeErr):
+				ss.unsolicited(kproto.Command_Status_INVALID_REQUEST, err.Error())
+			}
+			return
+		}
+		if !ss.process(f) {
+			return
+		}
+	}
+}
+
+// reject sends unsolicited status instead of the handshake, and closes the connection.
+func (ss *session) reject(code kproto.Command_Status_StatusCode, msg string) {
+	defer ss.cancel()
+	defer ss.conn.Close()
+	ss.conn.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	ss.unsolicited(code, msg)
+}
+
+// key returns HMAC key of identity, for codec.
+func (ss *session) key(identity int64) ([]byte, bool) {
+	acl, ok := ss.srv.acl(identity)
+	if !ok {
+		return nil, false
+	}
+	return acl.Key, true
+}
+
+func (ss *session) handshake() error {
+	conf := convert.LogToProto(&kinetic.Log{Configuration: ss.srv.configuration(), Limits: &ss.srv.limits})
+	conf.Types = []kproto.Command_GetLog_Type{kproto.Command_GetLog_CONFIGURATION, kproto.Command_GetLog_LIMITS}
+	cmd := &kproto.Command{
+		Header: &kproto.Command_Header{
+			ConnectionID:   proto.Int64(ss.connID),
+			ClusterVersion: proto.Int64(ss.srv.ClusterVersion()),
+		},
+		Body:   &kproto.Command_Body{GetLog: conf},
+		Status: &kproto.Command_Status{Code: kproto.Command_Status_SUCCESS.Enum()},
+	}
+	return ss.write(&codec.Frame{Message: &kproto.Message{AuthType: kproto.Message_UNSOLICITEDSTATUS.Enum()}, Command: cmd})
+}
+
+// unsolicited sends status not related to any request, before connection is closed.
+func (ss *session) unsolicited(code kproto.Command_Status_StatusCode, msg string) {
+	cmd := &kproto.Command{Status: &kproto.Command_Status{Code: code.Enum(), StatusMessage: proto.String(msg)}}
+	ss.write(&codec.Frame{Message: &kproto.Message{AuthType: kproto.Message_UNSOLICITEDSTATUS.Enum()}, Command: cmd})
+}
+
+func (ss *session) write(f *codec.Frame) error {
+	ss.wmu.Lock()
+	defer ss.wmu.Unlock()
+	w := codec.NewWriter(ss.conn)
+	w.Key = ss.key
+	return w.Write(f)
+}
+
+// process handles one request, returns false if connection should be closed.
+func (ss *session) process(f *codec.Frame) bool {
+	req := &request{frame: f, ctx: ss.ctx}
+	switch f.Message.GetAuthType() {
+	case kproto.Message_HMACAUTH:
+		identity := f.Message.GetHmacAuth().GetIdentity()
+		req.acl, _ = ss.srv.acl(identity)
+		req.ctx = context.WithValue(ss.ctx, identityKey, identity)
+	case kproto.Message_PINAUTH:
+		if !ss.tls {
+			ss.unsolicited(kproto.Command_Status_NOT_AUTHORIZED, "PIN operation requires TLS connection")
+			return false
+		}
+	default:
+		ss.unsolicited(kproto.Command_Status_INVALID_REQUEST, "Unknown authentication type")
+		return false
+	}
+	if f.Command.GetHeader() == nil {
+		ss.unsolicited(kproto.Command_Status_HEADER_REQUIRED, "Header required")
+		return false
+	}
+
+	t := req.header().GetMessageType()
+	if ss.srv.onRequest != nil {
+		ss.srv.onRequest(convert.MessageTypeFromProto(t), len(f.Value))
+	}
+	if resp := ss.checkState(req); resp != nil {
+		return ss.respond(req, resp)
+	}
+
+	if req.header().BatchID != nil && (t == kproto.Command_PUT || t == kproto.Command_DELETE) {
+		// Batch operation is only acknowledged on failure.
+		if resp := ss.batchOp(req); resp != nil {
+			return ss.respond(req, resp)
+		}
+		return true
+	}
+	return ss.respond(req, ss.dispatch(req))
+}
+
+// checkState checks cluster version, device lock and power level for request.
+func (ss *session) checkState(req *request) *response {
+	srv := ss.srv
+	srv.mu.Lock()
+	version, locked, power := srv.clusterVersion, srv.locked, srv.power
+	srv.mu.Unlock()
+
+	t := req.header().GetMessageType()
+	switch {
+	case req.header().GetClusterVersion() != version:
+		return &response{code: kproto.Command_Status_VERSION_FAILURE, msg: "Cluster version mismatch"}
+	case locked && t != kproto.Command_PINOP:
+		return &response{code: kproto.Command_Status_DEVICE_LOCKED, msg: "Device locked"}
+	case power == kinetic.PowerLevelHibernate &&
+		t != kproto.Command_SET_POWER_LEVEL && t != kproto.Command_GETLOG && t != kproto.Command_NOOP:
+		return &response{code: kproto.Command_Status_HIBERNATE, msg: "Device hibernating"}
+	case power == kinetic.PowerLevelShutdown || power == kinetic.PowerLevelFail:
+		return &response{code: kproto.Command_Status_SHUTDOWN, msg: "Device shut down"}
+	}
+	return nil
+}
+
+// responseTypes maps request message types to their response message types.
+var responseTypes = map[kproto.Command_MessageType]kproto.Command_MessageType{
+	kproto.Command_GET:             kproto.Command_GET_RESPONSE,
+	kproto.Command_PUT:             kproto.Command_PUT_RESPONSE,
+	kproto.Command_DELETE:          kproto.Command_DELETE_RESPONSE,
+	kproto.Command_GETNEXT:         kproto.Command_GETNEXT_RESPONSE,
+	kproto.Command_GETPREVIOUS:     kproto.Command_GETPREVIOUS_RESPONSE,
+	kproto.Command_GETKEYRANGE:     kproto.Command_GETKEYRANGE_RESPONSE,
+	kproto.Command_GETVERSION:      kproto.Command_GETVERSION_RESPONSE,
+	kproto.Command_SETUP:           kproto.Command_SETUP_RESPONSE,
+	kproto.Command_GETLOG:          kproto.Command_GETLOG_RESPONSE,
+	kproto.Command_SECURITY:        kproto.Command_SECURITY_RESPONSE,
+	kproto.Command_PEER2PEERPUSH:   kproto.Command_PEER2PEERPUSH_RESPONSE,
+	kproto.Command_NOOP:            kproto.Command_NOOP_RESPONSE,
+	kproto.Command_FLUSHALLDATA:    kproto.Command_FLUSHALLDATA_RESPONSE,
+	kproto.Command_PINOP:           kproto.Command_PINOP_RESPONSE,
+	kproto.Command_MEDIASCAN:       kproto.Command_MEDIASCAN_RESPONSE,
+	kproto.Command_MEDIAOPTIMIZE:   kproto.Command_MEDIAOPTIMIZE_RESPONSE,
+	kproto.Command_START_BATCH:     kproto.Command_START_BATCH_RESPONSE,
+	kproto.Command_END_BATCH:       kproto.Command_END_BATCH_RESPONSE,
+	kproto.Command_ABORT_BATCH:     kproto.Command_ABORT_BATCH_RESPONSE,
+	kproto.Command_SET_POWER_LEVEL: kproto.Command_SET_POWER_LEVEL_RESPONSE,
+}
+
+// responseType returns the response message type of request message type t, INVALID_MESSAGE_TYPE if unknown.
+func responseType(t kproto.Command_MessageType) kproto.Command_MessageType {
+	if r, ok := responseTypes[t]; ok {
+		return r
+	}
+	return kproto.Command_INVALID_MESSAGE_TYPE
+}
+
+func (ss *session) respond(req *request, resp *response) bool {
+	h := req.header()
+	cmd := &kproto.Command{
+		Header: &kproto.Command_Header{
+			AckSequence:  proto.Int64(h.GetSequence()),
+			MessageType:  responseType(h.GetMessageType()).Enum(),
+			ConnectionID: proto.Int64(ss.connID),
+		},
+		Body:   resp.body,
+		Status: &kproto.Command_Status{Code: resp.code.Enum()},
+	}
+	if resp.msg != "" {
+		cmd.Status.StatusMessage = proto.String(resp.msg)
+	}
+	if resp.code == kproto.Command_Status_VERSION_FAILURE {
+		cmd.Header.ClusterVersion = proto.Int64(ss.srv.ClusterVersion())
+	}
+
+	msg := &kproto.Message{AuthType: req.frame.Message.AuthType}
+	if req.acl != nil {
+		msg.HmacAuth = &kproto.Message_HMACauth{Identity: proto.Int64(req.acl.Identity)}
+	}
+	return ss.write(&codec.Frame{Message: msg, Command: cmd, Value: resp.value}) == nil
+}
+
+// allowed returns true if ACL of request grants permission on key. nil key is allowed only by
+// scopes without key restriction.
+func (ss *session) allowed(req *request, perm kinetic.ACLPermission, key []byte) bool {
+	if req.acl == nil {
+		return false
+	}
+	for _, scope := range req.acl.Scopes {
+		if scope.TLSRequired && !ss.tls {
+			continue
+		}
+		if len(scope.Value) > 0 {
+			if key == nil || scope.Offset < 0 || int(scope.Offset)+len(scope.Value) > len(key) ||
+				!bytes.Equal(key[scope.Offset:int(scope.Offset)+len(scope.Value)], scope.Value) {
+				continue
+			}
+		}
+		for _, p := range scope.Permissions {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var notAuthorized = &response{code: kproto.Command_Status_NOT_AUTHORIZED, msg: "Permission denied"}
+
+// checkRecord checks sizes of record against limits.
+func (ss *session) checkRecord(r *kinetic.Record) *response {
+	l := ss.srv.limits
+	switch {
+	case uint32(len(r.Key)) > l.MaxKeySize:
+		return invalid("Key too long")
+	case uint32(len(r.Value)) > l.MaxValueSize:
+		return invalid("Value too long")
+	case uint32(len(r.Version)) > l.MaxVersionSize || uint32(len(r.NewVersion)) > l.MaxVersionSize:
+		return invalid("Version too long")
+	case uint32(len(r.Tag)) > l.MaxTagSize:
+		return invalid("Tag too long")
+	}
+	return nil
+}
+
+func (ss *session) dispatch(req *request) *response {
+	backend := ss.srv.backend
+	t := req.header().GetMessageType()
+	if req.frame.Message.GetAuthType() == kproto.Message_PINAUTH && t != kproto.Command_PINOP {
+		return invalid("PIN authentication is only for PIN operation")
+	}
+	switch t {
+	case kproto.Command_NOOP, kproto.Command_FLUSHALLDATA:
+		return success
+
+	case kproto.Command_MEDIASCAN, kproto.Command_MEDIAOPTIMIZE:
+		if !ss.allowed(req, kinetic.ACLPermissionRange, nil) {
+			return notAuthorized
+		}
+		return success
+
+	case kproto.Command_GET, kproto.Command_GETNEXT, kproto.Command_GETPREVIOUS, kproto.Command_GETVERSION:
+		return ss.get(req)
+
+	case kproto.Command_PUT:
+		r := convert.RecordFromProto(req.keyValue(), req.frame.Value)
+		if !ss.allowed(req, kinetic.ACLPermissionWrite, r.Key) {
+			return notAuthorized
+		}
+		if resp := ss.checkRecord(r); resp != nil {
+			return resp
+		}
+		if err := backend.Put(req.ctx, r); err != nil {
+			return fail(err)
+		}
+		return success
+
+	case kproto.Command_DELETE:
+		r := convert.RecordFromProto(req.keyValue(), nil)
+		if !ss.allowed(req, kinetic.ACLPermissionDelete, r.Key) {
+			return notAuthorized
+		}
+		if resp := ss.checkRecord(r); resp != nil {
+			return resp
+		}
+		if err := backend.Delete(req.ctx, r); err != nil {
+			return fail(err)
+		}
+		return success
+
+	case kproto.Command_GETKEYRANGE:
+		return ss.getKeyRange(req)
+
+	case kproto.Command_START_BATCH, kproto.Command_END_BATCH, kproto.Command_ABORT_BATCH:
+		return ss.batch(req)
+
+	case kproto.Command_GETLOG:
+		return ss.getLog(req)
+
+	case kproto.Command_SETUP:
+		return ss.setup(req)
+
+	case kproto.Command_SECURITY:
+		return ss.security(req)
+
+	case kproto.Command_PINOP:
+		return ss.pinop(req)
+
+	case kproto.Command_SET_POWER_LEVEL:
+		if !ss.allowed(req, kinetic.ACLPermissionPowerManagement, nil) {
+			return notAuthorized
+		}
+		ss.srv.mu.Lock()
+		ss.srv.power = convert.PowerLevelFromProto(req.frame.Command.GetBody().GetPower().GetLevel())
+		ss.srv.mu.Unlock()
+		return success
+	}
+	return invalid("Message type not supported")
+}
+
+func (ss *session) get(req *request) *response {
+	t := req.header().GetMessageType()
+	key := req.keyValue().GetKey()
+	metaOnly := req.keyValue().GetMetadataOnly() || t == kproto.Command_GETVERSION
+
+	var r *kinetic.Record
+	var err error
+	switch t {
+	case kproto.Command_GETNEXT:
+		r, err = ss.srv.backend.GetNext(req.ctx, key, metaOnly)
+	case kproto.Command_GETPREVIOUS:
+		r, err = ss.srv.backend.GetPrevious(req.ctx, key, metaOnly)
+	default:
+		if !ss.allowed(req, kinetic.ACLPermissionRead, key) {
+			return notAuthorized
+		}
+		r, err = ss.srv.backend.Get(req.ctx, key, metaOnly)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if r == nil {
+		return failCode(kinetic.RemoteNotFound, "Key not found")
+	}
+	// For GETNEXT and GETPREVIOUS, permission is required on the returned key.
+	if !ss.allowed(req, kinetic.ACLPermissionRead, r.Key) {
+		return notAuthorized
+	}
+
+	if t == kproto.Command_GETVERSION {
+		return reply(&kproto.Command_Body{KeyValue: &kproto.Command_KeyValue{DbVersion: r.Version}})
+	}
+	kv := convert.RecordToProto(r)
+	resp := reply(&kproto.Command_Body{KeyValue: kv})
+	if metaOnly {
+		kv.MetadataOnly = proto.Bool(true)
+	} else {
+		resp.value = r.Value
+	}
+	return resp
+}
+
+func (ss *session) getKeyRange(req *request) *response {
+	kr := convert.KeyRangeFromProto(req.frame.Command.GetBody().GetRange())
+	if kr.Max <= 0 || uint32(kr.Max) > ss.srv.limits.MaxKeyRangeCount {
+		return invalid("Invalid max returned")
+	}
+	if !ss.allowed(req, kinetic.ACLPermissionRange, nil) && !ss.allowed(req, kinetic.ACLPermissionRange, kr.StartKey) {
+		return notAuthorized
+	}
+
+	keys, err := ss.srv.backend.GetKeyRange(req.ctx, kr)
+	if err != nil {
+		return fail(err)
+	}
+	// Keys out of scope are not returned.
+	allowed := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		if ss.allowed(req, kinetic.ACLPermissionRange, key) {
+			allowed = append(allowed, key)
+		}
+	}
+	return reply(&kproto.Command_Body{Range: &kproto.Command_Range{Keys: allowed}})
+}
+
+// batchOp queues PUT or DELETE of batch, returns response only if the operation fails.
+func (ss *session) batchOp(req *request) *response {
+	id := req.header().GetBatchID()
+	ops, ok := ss.batches[id]
+	if !ok {
+		return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Unknown batch"}
+	}
+	if uint32(len(ops)) >= ss.srv.limits.MaxOperationCountPerBatch {
+		return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Too many operations in batch"}
+	}
+
+	op := BatchOp{Type: kinetic.MessagePut, Sequence: req.header().GetSequence()}
+	perm := kinetic.ACLPermissionWrite
+	if req.header().GetMessageType() == kproto.Command_DELETE {
+		op.Type, perm = kinetic.MessageDelete, kinetic.ACLPermissionDelete
+		op.Record = convert.RecordFromProto(req.keyValue(), nil)
+	} else {
+		op.Record = convert.RecordFromProto(req.keyValue(), req.frame.Value)
+	}
+	if !ss.allowed(req, perm, op.Record.Key) {
+		return notAuthorized
+	}
+	if resp := ss.checkRecord(op.Record); resp != nil {
+		return resp
+	}
+	ss.batches[id] = append(ops, op)
+	return nil
+}
+
+func (ss *session) batch(req *request) *response {
+	srv := ss.srv
+	id := req.header().GetBatchID()
+	ops, ok := ss.batches[id]
+
+	switch req.header().GetMessageType() {
+	case kproto.Command_START_BATCH:
+		if ok {
+			return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Batch already started"}
+		}
+		srv.mu.Lock()
+		if uint32(srv.batches) >= srv.limits.MaxBatchCountPerDevice {
+			srv.mu.Unlock()
+			return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Too many batches"}
+		}
+		srv.batches++
+		srv.mu.Unlock()
+		ss.batches[id] = []BatchOp{}
+		return success
+
+	case kproto.Command_ABORT_BATCH:
+		if !ok {
+			return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Unknown batch"}
+		}
+		ss.endBatch(id)
+		return success
+	}
+
+	// END_BATCH
+	if !ok {
+		return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Unknown batch"}
+	}
+	ss.endBatch(id)
+	if int(req.frame.Command.GetBody().GetBatch().GetCount()) != len(ops) {
+		return &response{code: kproto.Command_Status_INVALID_BATCH, msg: "Batch operation count mismatch"}
+	}
+
+	err := srv.backend.Batch(req.ctx, ops)
+	if err != nil {
+		resp := fail(err)
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			resp.body = &kproto.Command_Body{Batch: &kproto.Command_Batch{FailedSequence: proto.Int64(batchErr.Sequence)}}
+		}
+		return resp
+	}
+	batch := &kproto.Command_Batch{Count: proto.Int32(int32(len(ops)))}
+	for _, op := range ops {
+		batch.Sequence = append(batch.Sequence, op.Sequence)
+	}
+	return reply(&kproto.Command_Body{Batch: batch})
+}
+
+// endBatch removes batch from session.
+func (ss *session) endBatch(id uint32) {
+	delete(ss.batches, id)
+	ss.srv.mu.Lock()
+	ss.srv.batches--
+	ss.srv.mu.Unlock()
+}
+
+// abortBatches removes all open batches when connection closes.
+func (ss *session) abortBatches() {
+	for id := range ss.batches {
+		ss.endBatch(id)
+	}
+}
+
+func (ss *session) getLog(req *request) *response {
+	if !ss.allowed(req, kinetic.ACLPermissionGetLog, nil) {
+		return notAuthorized
+	}
+
+	ptypes := req.frame.Command.GetBody().GetGetLog().GetTypes()
+	types := make([]kinetic.LogType, len(ptypes))
+	for k, t := range ptypes {
+		types[k] = convert.LogTypeFromProto(t)
+	}
+	log, err := ss.srv.backend.GetLog(req.ctx, types)
+	if err != nil {
+		return fail(err)
+	}
+	if log == nil {
+		log = &kinetic.Log{}
+	}
+	for _, t := range types {
+		switch {
+		case t == kinetic.LogTypeConfiguration && log.Configuration == nil:
+			log.Configuration = ss.srv.configuration()
+		case t == kinetic.LogTypeLimits && log.Limits == nil:
+			limits := ss.srv.limits
+			log.Limits = &limits
+		}
+	}
+
+	getlog := convert.LogToProto(log)
+	getlog.Types = ptypes
+	return reply(&kproto.Command_Body{GetLog: getlog})
+}
+
+func (ss *session) setup(req *request) *response {
+	if !ss.allowed(req, kinetic.ACLPermissionSetup, nil) {
+		return notAuthorized
+	}
+	ps := req.frame.Command.GetBody().GetSetup()
+	if ps == nil {
+		return invalid("Setup required")
+	}
+
+	s := &Setup{NewClusterVersion: ps.NewClusterVersion, FirmwareDownload: ps.GetFirmwareDownload()}
+	if s.FirmwareDownload {
+		s.Firmware = req.frame.Value
+	}
+	if err := ss.srv.backend.Setup(req.ctx, s); err != nil {
+		return fail(err)
+	}
+	if s.NewClusterVersion != nil {
+		ss.srv.mu.Lock()
+		ss.srv.clusterVersion = *s.NewClusterVersion
+		ss.srv.mu.Unlock()
+	}
+	return success
+}
+
+func (ss *session) security(req *request) *response {
+	if !ss.allowed(req, kinetic.ACLPermissionSecurity, nil) {
+		return notAuthorized
+	}
+	if !ss.tls {
+		return &response{code: kproto.Command_Status_NOT_AUTHORIZED, msg: "Security requires TLS connection"}
+	}
+	sec := req.frame.Command.GetBody().GetSecurity()
+	if sec == nil {
+		return invalid("Security required")
+	}
+
+	srv := ss.srv
+	var acls map[int64]*kinetic.ACL
+	if len(sec.GetAcl()) > 0 {
+		if uint32(len(sec.GetAcl())) > srv.limits.MaxIdentityCount {
+			return invalid("Too many identities")
+		}
+		acls = make(map[int64]*kinetic.ACL)
+		for _, a := range sec.GetAcl() {
+			if a.GetHmacAlgorithm() != kproto.Command_Security_ACL_HmacSHA1 {
+				return &response{code: kproto.Command_Status_NO_SUCH_HMAC_ALGORITHM, msg: "HMAC algorithm not supported"}
+			}
+			if len(a.GetKey()) == 0 {
+				return invalid("ACL key required")
+			}
+			acl := convert.ACLFromProto(a)
+			acls[acl.Identity] = &acl
+		}
+	}
+	for _, pin := range [][]byte{sec.GetNewLockPIN(), sec.GetNewErasePIN()} {
+		if uint32(len(pin)) > srv.limits.MaxPinSize {
+			return invalid("PIN too long")
+		}
+	}
+
+	// Nothing is changed if any PIN doesn't match.
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if sec.NewLockPIN != nil && !bytes.Equal(sec.GetOldLockPIN(), srv.lockPin) {
+		return &response{code: kproto.Command_Status_NOT_AUTHORIZED, msg: "Lock PIN mismatch"}
+	}
+	if sec.NewErasePIN != nil && !bytes.Equal(sec.GetOldErasePIN(), srv.erasePin) {
+		return &response{code: kproto.Command_Status_NOT_AUTHORIZED, msg: "Erase PIN mismatch"}
+	}
+	if acls != nil {
+		srv.acls = acls
+	}
+	if sec.NewLockPIN != nil {
+		srv.lockPin = sec.GetNewLockPIN()
+	}
+	if sec.NewErasePIN != nil {
+		srv.erasePin = sec.GetNewErasePIN()
+	}
+	return success
+}
+
+func (ss *session) pinop(req *request) *response {
+	if req.frame.Message.GetAuthType() != kproto.Message_PINAUTH {
+		return invalid("PIN operation requires PIN authentication")
+	}
+	srv := ss.srv
+	pin := req.frame.Message.GetPinAuth().GetPin()
+	mismatch := &response{code: kproto.Command_Status_NOT_AUTHORIZED, msg: "PIN mismatch"}
+
+	switch op := req.frame.Command.GetBody().GetPinOp().GetPinOpType(); op {
+	case kproto.Command_PinOperation_ERASE_PINOP, kproto.Command_PinOperation_SECURE_ERASE_PINOP:
+		srv.mu.Lock()
+		ok := bytes.Equal(pin, srv.erasePin)
+		srv.mu.Unlock()
+		if !ok {
+			return mismatch
+		}
+		eraser, ok := srv.backend.(Eraser)
+		if !ok {
+			return invalid("Erase not supported")
+		}
+		if err := eraser.Erase(req.ctx, op == kproto.Command_PinOperation_SECURE_ERASE_PINOP); err != nil {
+			return fail(err)
+		}
+		return success
+
+	case kproto.Command_PinOperation_LOCK_PINOP, kproto.Command_PinOperation_UNLOCK_PINOP:
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		lock := op == kproto.Command_PinOperation_LOCK_PINOP
+		switch {
+		case lock && len(srv.lockPin) == 0:
+			return invalid("Lock PIN not set")
+		case !bytes.Equal(pin, srv.lockPin):
+			return mismatch
+		case !lock && !srv.locked:
+			return &response{code: kproto.Command_Status_DEVICE_ALREADY_UNLOCKED, msg: "Device not locked"}
+		}
+		srv.locked = lock
+		return success
+	}
+	return invalid("Unknown PIN operation")
+}
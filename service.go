@@ -18,15 +18,16 @@
 package kinetic
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/binary"
 	"errors"
-	"fmt"
-	"io"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Kinetic/kinetic-go/codec"
 	kproto "github.com/Kinetic/kinetic-go/proto"
 	proto "github.com/golang/protobuf/proto"
 )
@@ -36,10 +37,38 @@ const (
 	defaultRequestTimeout    = 60 * time.Second
 )
 
-var (
-	connectionTimeout = defaultConnectionTimeout
-	requestTimeout    = defaultRequestTimeout
-)
+// timeouts for single network connection, see ClientOptions.
+type timeouts struct {
+	connect time.Duration // Timeout to establish connection
+	read    time.Duration // Timeout waiting for response message while requests outstanding
+	write   time.Duration // Timeout sending request message
+	idle    time.Duration // Connection closed if no request outstanding for this long, 0 for never
+}
+
+func newTimeouts(op ClientOptions) timeouts {
+	t := timeouts{
+		connect: defaultConnectionTimeout,
+		read:    defaultRequestTimeout,
+		write:   defaultRequestTimeout,
+	}
+	if op.Timeout > 0 {
+		t.connect = time.Duration(op.Timeout) * time.Millisecond
+	}
+	if op.RequestTimeout > 0 {
+		t.read = time.Duration(op.RequestTimeout) * time.Millisecond
+		t.write = t.read
+	}
+	if op.ReadTimeout > 0 {
+		t.read = time.Duration(op.ReadTimeout) * time.Millisecond
+	}
+	if op.WriteTimeout > 0 {
+		t.write = time.Duration(op.WriteTimeout) * time.Millisecond
+	}
+	if op.IdleTimeout > 0 {
+		t.idle = time.Duration(op.IdleTimeout) * time.Millisecond
+	}
+	return t
+}
 
 func newMessage(t kproto.Message_AuthType) *kproto.Message {
 	msg := &kproto.Message{
@@ -61,174 +90,579 @@ func newCommand(t kproto.Command_MessageType) *kproto.Command {
 }
 
 type networkService struct {
-	rxMu           sync.Mutex
 	txMu           sync.Mutex
 	mapMu          sync.Mutex
 	conn           net.Conn
-	clusterVersion int64                      // Cluster version
-	seq            int64                      // Operation sequence ID
-	connID         int64                      // current connection ID
-	option         ClientOptions              // current connection operation
-	hmap           map[int64]*ResponseHandler // Message handler map
-	fatal          bool                       // Network has fatal failure
-	fatalError     error                      // Network fatal error details
-	device         Log                        // Store device information from handshake package
+	clusterVersion int64                         // Cluster version
+	seq            int64                         // Operation sequence ID
+	connID         int64                         // current connection ID
+	option         ClientOptions                 // current connection operation
+	timeouts       timeouts                      // Network timeouts for this connection
+	deadline       time.Time                     // Current read deadline of network connection
+	receiving      bool                          // Message partially received, read deadline is owned by receive
+	hmap           map[int64]*ResponseHandler    // Message handler map
+	abandoned      map[int64]time.Time           // Sequence of requests abandoned before response received, to expiry
+	admission      atomic.Pointer[admission]     // Outstanding request admission control, rebuilt after reconnect
+	subscribers    []subscriber                  // Subscribers of unsolicited status
+	subscriberID   int                           // ID for next subscriber
+	fatal          bool                          // Network has fatal failure
+	fatalError     error                         // Network fatal error details
+	reconnecting   bool                          // Network failed, reconnect in progress
+	closed         bool                          // Connection closed by client
+	closing        chan struct{}                 // Closed when connection closed by client
+	drained        chan struct{}                 // Closed when no request outstanding, while shutting down
+	done           chan struct{}                 // Closed when listen goroutine exits
+	device         Log                           // Store device information from handshake package
+	readLimits     codec.Limits                  // Maximum response size, from handshake. Owned by listen goroutine
+	logger         atomic.Pointer[contextLogger] // Logger with host and current connection ID
 }
 
-func newNetworkService(op ClientOptions) (*networkService, error) {
-	var conn net.Conn
-	var err error
+// ErrConnectionClosed is returned when submitting request after connection closed.
+var ErrConnectionClosed = errors.New("Connection closed")
 
-	if op.Timeout > 0 {
-		connectionTimeout = time.Duration(op.Timeout) * time.Millisecond
-	}
-	if op.RequestTimeout > 0 {
-		requestTimeout = time.Duration(op.RequestTimeout) * time.Millisecond
-	}
-
-	target := fmt.Sprintf("%s:%d", op.Host, op.Port)
-	if op.UseSSL {
-		// TODO: Need to enable verify certification later
-		config := tls.Config{InsecureSkipVerify: true}
-		d := &net.Dialer{Timeout: connectionTimeout}
-		conn, err = tls.DialWithDialer(d, "tcp", target, &config)
-	} else {
-		conn, err = net.DialTimeout("tcp", target, connectionTimeout)
-	}
+// errResponseHMAC is returned by receive when response message HMAC doesn't match.
+var errResponseHMAC = errors.New("Response HMAC mismatch")
 
-	if err != nil {
-		klog.Error("Can't establish connection to ", op.Host, err)
-		return nil, err
-	}
+// errReadTimeout is returned by receive when read deadline expired before any byte of message received.
+var errReadTimeout = errors.New("Network I/O read timeout")
 
+func newNetworkService(op ClientOptions) (*networkService, error) {
 	ns := &networkService{
-		conn:           conn,
 		clusterVersion: 0,
 		seq:            0,
 		connID:         -1,
 		option:         op,
+		timeouts:       newTimeouts(op),
 		hmap:           make(map[int64]*ResponseHandler),
+		abandoned:      make(map[int64]time.Time),
 		fatal:          false,
 		fatalError:     nil,
+		closing:        make(chan struct{}),
+		done:           make(chan struct{}),
 	}
+	ns.logger.Store(newContextLogger(op.Logger, Fields{FieldHost: op.Host}))
 
-	ns.rxMu.Lock()
-	// Do the handshake.
-	// Device Configuration and Limits from handshake will be stored in networkService.device
-	_, _, _, err = ns.receive()
-	ns.rxMu.Unlock()
-
+	conn, clusterVersion, err := ns.connect()
 	if err != nil {
-		klog.Error("Can't establish connection to %s", op.Host)
 		return nil, err
 	}
+	ns.conn = conn
+	ns.clusterVersion = clusterVersion
+	ns.mapMu.Lock()
+	ns.updateReadDeadline()
+	ns.mapMu.Unlock()
 
-	klog.Debugf("Connected to %s:%d", op.Host, op.Port)
-	klog.Debugf("\tVendor: %s", ns.device.Configuration.Vendor)
-	klog.Debugf("\tModel: %s", ns.device.Configuration.Model)
-	klog.Debugf("\tWorldWideName: %s", ns.device.Configuration.WorldWideName)
-	klog.Debugf("\tSerial Number: %s", ns.device.Configuration.SerialNumber)
-	klog.Debugf("\tFirmware Version: %s", ns.device.Configuration.Version)
-	klog.Debugf("\tKinetic Protocol Version: %s", ns.device.Configuration.ProtocolVersion)
-	klog.Debugf("\tPort: %d", ns.device.Configuration.Port)
-	klog.Debugf("\tTlsPort: %d", ns.device.Configuration.TLSPort)
-	klog.Debugf("\tCurrentPowerLevel : %s", ns.device.Configuration.CurrentPowerLevel.String())
+	// From now on, listen goroutine owns the read side of network connection.
+	go ns.listen()
 
 	return ns, nil
 }
 
+// connect establishes network connection to kinetic device and does the handshake.
+// Device Configuration and Limits from handshake will be stored in networkService.device, and admission
+// control is rebuilt from the Limits. Connection ID from handshake will be used for following requests.
+// Returns the connection and cluster version of kinetic device from handshake.
+func (ns *networkService) connect() (net.Conn, int64, error) {
+	var conn net.Conn
+	var err error
+
+	op := ns.option
+	target := net.JoinHostPort(op.Host, strconv.Itoa(op.Port))
+	if op.UseSSL {
+		d := &net.Dialer{Timeout: ns.timeouts.connect}
+		conn, err = tls.DialWithDialer(d, "tcp", target, newTLSConfig(op))
+		if err != nil && isTLSVerificationError(err) {
+			err = &TLSVerificationError{Host: target, Err: err}
+		}
+	} else {
+		conn, err = net.DialTimeout("tcp", target, ns.timeouts.connect)
+	}
+
+	if err != nil {
+		ns.log().error("Can't establish connection", Fields{FieldError: err.Error()})
+		return nil, 0, err
+	}
+
+	// Do the handshake.
+	conn.SetReadDeadline(time.Now().Add(ns.timeouts.read))
+	_, cmd, _, err := ns.receive(conn, responseLimits(op, nil))
+	if err == nil && (cmd.GetHeader() == nil || cmd.GetHeader().ConnectionID == nil) {
+		err = errors.New("Handshake message without connection ID")
+	}
+	if err != nil {
+		ns.log().error("Can't establish connection", Fields{FieldError: err.Error()})
+		conn.Close()
+		return nil, 0, err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	device := getLogFromProto(cmd)
+	ns.txMu.Lock()
+	ns.mapMu.Lock()
+	ns.device = device
+	ns.connID = cmd.GetHeader().GetConnectionID()
+	ns.mapMu.Unlock()
+	ns.txMu.Unlock()
+	ns.admission.Store(newAdmission(op.FlowControl, device.Limits))
+	ns.readLimits = responseLimits(op, device.Limits)
+	config := device.Configuration
+
+	ns.logger.Store(newContextLogger(op.Logger, Fields{FieldHost: op.Host, FieldConnectionID: cmd.GetHeader().GetConnectionID()}))
+	ns.log().debug("Connected", Fields{
+		"port":                op.Port,
+		"vendor":              config.Vendor,
+		"model":               config.Model,
+		"world_wide_name":     config.WorldWideName,
+		"serial_number":       config.SerialNumber,
+		"firmware_version":    config.Version,
+		"protocol_version":    config.ProtocolVersion,
+		"device_port":         config.Port,
+		"device_tls_port":     config.TLSPort,
+		"current_power_level": config.CurrentPowerLevel.String(),
+	})
+
+	return conn, cmd.GetHeader().GetClusterVersion(), nil
+}
+
 // When client network service has error, call error handling
 // from all Messagehandler current in Queue.
-func (ns *networkService) clientError(s Status, mh *ResponseHandler) {
+// If err is not nil, network service is marked as fatal and no more request can be submitted.
+func (ns *networkService) clientError(s Status, err error) {
 	ns.mapMu.Lock()
+	if err != nil && !ns.fatal {
+		ns.fatal = true
+		ns.fatalError = err
+	}
+	handlers := make([]*ResponseHandler, 0, len(ns.hmap))
 	for ack, h := range ns.hmap {
-		h.fail(s)
+		handlers = append(handlers, h)
 		delete(ns.hmap, ack)
 	}
+	ns.abandoned = make(map[int64]time.Time)
 	ns.mapMu.Unlock()
 
-	if mh != nil {
-		mh.fail(s)
+	for _, h := range handlers {
+		h.releaseSlot()
+		h.fail(s, s)
 	}
 }
 
-func (ns *networkService) listen() error {
-	if ns.fatal {
-		return errors.New("Can't listen, network service has fatal error: " + ns.fatalError.Error())
+// listen is the network service reader. It runs in its own goroutine from handshake until
+// connection closed or network failure, and dispatches each response message to the
+// ResponseHandler registered for its ack sequence. Unsolicited status is delivered to subscribers.
+// If ClientOptions.Reconnect is set, listen reconnects to kinetic device on network failure.
+func (ns *networkService) listen() {
+	defer close(ns.done)
+
+	for {
+		msg, cmd, value, err := ns.receive(ns.conn, ns.readLimits)
+		if err == errResponseHMAC {
+			// Only the request the response is for fails, the stream is still in frame.
+			ns.reject(cmd, Status{Code: ClientResponseHMACError, ErrorMsg: err.Error()})
+			continue
+		}
+		if err == errReadTimeout {
+			if !ns.checkTimeout() {
+				continue
+			}
+			ns.log().debug("Connection idle timeout")
+			ns.mapMu.Lock()
+			ns.markClosed()
+			ns.mapMu.Unlock()
+			ns.conn.Close()
+		}
+		if err != nil {
+			if !ns.isClosed() {
+				ns.log().error("Network service listen error", Fields{FieldError: err.Error()})
+				if ns.option.Reconnect != nil && ns.reconnect(err) {
+					continue
+				}
+			}
+
+			if ns.isClosed() {
+				ns.clientError(Status{Code: ClientShutdown, ErrorMsg: "Connection closed"}, ErrConnectionClosed)
+			} else {
+				ns.clientError(Status{Code: ClientIOError, ErrorMsg: err.Error()}, err)
+			}
+			return
+		}
+
+		if isUnsolicited(msg, cmd) {
+			if ns.unsolicited(cmd) {
+				return
+			}
+			continue
+		}
+
+		ns.dispatch(cmd, value)
 	}
+}
 
+// reconnect re-establishes network connection after network failure, following ClientOptions.Reconnect.
+// ResponseHandlers in flight fail with ClientConnectionReset, except idempotent read requests which are
+// resubmitted if ReconnectPolicy.ResubmitReads is true. Requests submitted while reconnecting fail with
+// ClientConnectionReset. Client cluster version is restored after handshake.
+// Returns false if reconnect failed or connection closed by client.
+func (ns *networkService) reconnect(cause error) bool {
+	policy := ns.option.Reconnect
+
+	ns.conn.Close()
+
+	ns.txMu.Lock()
 	ns.mapMu.Lock()
-	if len(ns.hmap) == 0 {
-		ns.mapMu.Unlock()
-		return nil
+	ns.reconnecting = true
+	resubmit := make([]*ResponseHandler, 0)
+	failed := make([]*ResponseHandler, 0, len(ns.hmap))
+	for ack, h := range ns.hmap {
+		if policy.ResubmitReads && h.request != nil {
+			resubmit = append(resubmit, h)
+		} else {
+			failed = append(failed, h)
+		}
+		delete(ns.hmap, ack)
 	}
+	ns.abandoned = make(map[int64]time.Time)
 	ns.mapMu.Unlock()
+	ns.txMu.Unlock()
 
-	ns.rxMu.Lock()
-	msg, cmd, value, err := ns.receive()
-	ns.rxMu.Unlock()
-	if err != nil {
-		klog.Error("Network Service listen error")
-		return err
+	s := Status{Code: ClientConnectionReset, ErrorMsg: "Connection reset, request may not be completed, " + cause.Error()}
+	for _, h := range failed {
+		h.releaseSlot()
+		h.fail(s, s)
 	}
 
-	if cmd.GetHeader() != nil {
-		klog.Debug("Kinetic response received ", cmd.GetHeader().GetMessageType().String(),
-			", AckSeq = ", cmd.GetHeader().GetAckSequence(),
-			", Code = ", cmd.GetStatus().GetCode())
-	} else if msg.GetAuthType() == kproto.Message_UNSOLICITEDSTATUS {
-		klog.Debug("Kinetic UNSOLICITEDSTATUS : ",
-			"Code = ", cmd.GetStatus().GetCode(),
-			", StatusMessage = ", cmd.GetStatus().GetStatusMessage())
+	delay := policy.InitialDelay
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		select {
+		case <-ns.closing:
+			s := Status{Code: ClientShutdown, ErrorMsg: "Connection closed"}
+			for _, h := range resubmit {
+				h.releaseSlot()
+				h.fail(s, s)
+			}
+			return false
+		case <-time.After(delay):
+		}
+
+		ns.log().info("Reconnecting", Fields{"attempt": attempt})
+		conn, _, err := ns.connect()
+		if err == nil {
+			// Cluster version set by client is kept, not the one from handshake.
+			ns.txMu.Lock()
+			ns.mapMu.Lock()
+			closed := ns.closed
+			if !closed {
+				ns.conn = conn
+				ns.reconnecting = false
+				ns.updateReadDeadline()
+			}
+			ns.mapMu.Unlock()
+			if closed {
+				ns.txMu.Unlock()
+				conn.Close()
+				continue
+			}
+
+			// Resubmit failure is handled by its own ResponseHandler, and network failure
+			// will be seen by listen goroutine.
+			for _, h := range resubmit {
+				ns.sendLocked(h.request.msg, h.request.cmd, nil, h, h.request.timeout)
+			}
+			ns.txMu.Unlock()
+			return true
+		}
+
+		delay = policy.nextDelay(delay)
 	}
 
-	// For UNSOLICITEDSTATUS, command may not have Header or AckSequence, set the ack to -1 so
-	// no ResponseHandler will be found from hmap table.
-	var ack int64 = -1
-	if cmd.GetHeader() != nil {
-		ack = cmd.GetHeader().GetAckSequence()
+	s = Status{Code: ClientIOError, ErrorMsg: "Reconnect failed, " + cause.Error()}
+	for _, h := range resubmit {
+		h.releaseSlot()
+		h.fail(s, s)
 	}
+	return false
+}
 
+// isReconnecting returns true if network failed and reconnect is in progress.
+func (ns *networkService) isReconnecting() bool {
 	ns.mapMu.Lock()
-	h, ok := ns.hmap[ack]
-	ns.mapMu.Unlock()
-	if ok == false {
+	defer ns.mapMu.Unlock()
+	return ns.reconnecting
+}
+
+// markClosed marks connection closed, so no more request can be submitted and waiters on closing are
+// woken up. Must be called with mapMu held.
+func (ns *networkService) markClosed() {
+	if !ns.closed {
+		ns.closed = true
+		close(ns.closing)
+	}
+}
+
+func (ns *networkService) isClosed() bool {
+	ns.mapMu.Lock()
+	defer ns.mapMu.Unlock()
+	return ns.closed
+}
+
+// setClusterVersion sets the cluster version for all following requests.
+func (ns *networkService) setClusterVersion(version int64) {
+	ns.txMu.Lock()
+	ns.clusterVersion = version
+	ns.txMu.Unlock()
+}
+
+// deviceLog returns the device Configuration and Limits from handshake.
+func (ns *networkService) deviceLog() Log {
+	ns.mapMu.Lock()
+	defer ns.mapMu.Unlock()
+	return ns.device
+}
+
+// connectionID returns the connection ID assigned by kinetic device in handshake.
+func (ns *networkService) connectionID() int64 {
+	ns.mapMu.Lock()
+	defer ns.mapMu.Unlock()
+	return ns.connID
+}
+
+// log returns the logger with host and current connection ID.
+func (ns *networkService) log() *contextLogger {
+	return ns.logger.Load()
+}
+
+// getClusterVersion returns the cluster version for requests to kinetic device.
+func (ns *networkService) getClusterVersion() int64 {
+	ns.txMu.Lock()
+	defer ns.txMu.Unlock()
+	return ns.clusterVersion
+}
+
+// dispatch delivers response message to its ResponseHandler.
+func (ns *networkService) dispatch(cmd *kproto.Command, value []byte) {
+	ack := ackSequence(cmd)
+	if log := ns.log(); log.enabled(LogLevelDebug) {
+		log.debug("Kinetic response received", Fields{
+			FieldMessageType: cmd.GetHeader().GetMessageType().String(),
+			FieldSequence:    ack,
+			FieldStatus:      cmd.GetStatus().GetCode().String(),
+		})
+	}
+
+	h, abandoned := ns.take(ack)
+	if abandoned {
+		ns.log().debug("Response for abandoned request dropped", Fields{FieldSequence: ack})
+		return
+	}
+	if h == nil {
 		// It's high chance this is an UNSOLICITEDSTATUS message, display the Status.
-		klog.Errorf("Couldn't find a handler for acksequence %d, status=%s", ack, getStatusFromProto(cmd).String())
-		// This is an unexpected packet. Each listen() call expect remove one ResponseHandler from hmap.
-		// So need to fire another listen() to make sure ResponseHandler in hmap got chance to exit.
-		// Either by receive correct packet, or network read failure.
-		go ns.listen()
-		return nil
+		ns.log().error("Couldn't find a handler for response", Fields{FieldSequence: ack, FieldStatus: getStatusFromProto(cmd).String()})
+		return
 	}
 
+	// Release the slot before callback, so callback can submit new request.
+	h.releaseSlot()
 	h.handle(cmd, value)
+}
+
+// reject fails the ResponseHandler of response message which can't be accepted, eg. HMAC mismatch.
+// Other outstanding requests are not affected.
+func (ns *networkService) reject(cmd *kproto.Command, s Status) {
+	ack := ackSequence(cmd)
+	ns.log().error("Kinetic response rejected", Fields{FieldSequence: ack, FieldError: s.ErrorMsg})
+
+	if h, _ := ns.take(ack); h != nil {
+		h.releaseSlot()
+		h.fail(s, s)
+	}
+}
+
+// take removes and returns the ResponseHandler registered for ack, nil if not found.
+// abandoned is true if the request was abandoned before response received.
+func (ns *networkService) take(ack int64) (h *ResponseHandler, abandoned bool) {
+	ns.mapMu.Lock()
+	defer ns.mapMu.Unlock()
+	defer ns.updateReadDeadline()
+
+	if h, ok := ns.hmap[ack]; ok {
+		delete(ns.hmap, ack)
+		return h, false
+	}
+	if _, ok := ns.abandoned[ack]; ok {
+		delete(ns.abandoned, ack)
+		return nil, true
+	}
+	return nil, false
+}
+
+// abandon records sequence of request abandoned before response received, so its response is dropped
+// quietly if it still arrives. Records expire after read timeout, expired records are removed.
+// Must be called with mapMu held.
+func (ns *networkService) abandon(seq int64, now time.Time) {
+	for s, expiry := range ns.abandoned {
+		if !now.Before(expiry) {
+			delete(ns.abandoned, s)
+		}
+	}
+	ns.abandoned[seq] = now.Add(ns.timeouts.read)
+}
+
+// ackSequence returns the ack sequence of response message. For UNSOLICITEDSTATUS, command may not have
+// Header or AckSequence, -1 is returned so no ResponseHandler will be found.
+func ackSequence(cmd *kproto.Command) int64 {
+	if cmd.GetHeader() != nil && cmd.GetHeader().AckSequence != nil {
+		return cmd.GetHeader().GetAckSequence()
+	}
+	return -1
+}
+
+// updateReadDeadline sets read deadline to the earliest deadline of outstanding requests, if there is
+// any response outstanding. Otherwise set idle deadline, or clear read deadline so idle connection won't
+// timeout. Must be called with mapMu held.
+func (ns *networkService) updateReadDeadline() {
+	if len(ns.hmap) > 0 {
+		ns.deadline = time.Time{}
+		for _, h := range ns.hmap {
+			if ns.deadline.IsZero() || h.deadline.Before(ns.deadline) {
+				ns.deadline = h.deadline
+			}
+		}
+	} else {
+		ns.deadline = time.Time{}
+		if ns.timeouts.idle > 0 {
+			ns.deadline = time.Now().Add(ns.timeouts.idle)
+		}
+		if ns.drained != nil {
+			close(ns.drained)
+			ns.drained = nil
+		}
+	}
+	if !ns.receiving {
+		ns.conn.SetReadDeadline(ns.deadline)
+	}
+}
+
+// checkTimeout handles read timeout. Outstanding requests past their deadline are removed, release their
+// outstanding request slot and fail with ClientRequestTimeout, other requests keep waiting for response.
+// Returns true if connection idle timeout.
+func (ns *networkService) checkTimeout() (idle bool) {
+	now := time.Now()
+	expired := make([]*ResponseHandler, 0)
 
 	ns.mapMu.Lock()
-	delete(ns.hmap, ack)
+	if len(ns.hmap) == 0 {
+		idle = !ns.deadline.IsZero() && !now.Before(ns.deadline)
+	}
+	for seq, h := range ns.hmap {
+		if !now.Before(h.deadline) {
+			expired = append(expired, h)
+			delete(ns.hmap, seq)
+			// Response may still arrive, drop it quietly.
+			ns.abandon(seq, now)
+		}
+	}
+	if !idle {
+		ns.updateReadDeadline()
+	}
 	ns.mapMu.Unlock()
 
-	return nil
+	for _, h := range expired {
+		h.logger().debug("Request timeout")
+		s := Status{Code: ClientRequestTimeout, ErrorMsg: "Request timeout waiting for response"}
+		h.releaseSlot()
+		h.fail(s, s)
+	}
+	return idle
 }
 
 // submit will send the message to kinetic device, insert ResponseHandler for this message sequence number.
 // ResponseHandler can be nil if the message no require for Ack, eg batch PUT / DELETE.
-func (ns *networkService) submit(msg *kproto.Message, cmd *kproto.Command, value []byte, h *ResponseHandler) error {
-	if ns.fatal {
-		return errors.New("Can't submit, network service has fatal error: " + ns.fatalError.Error())
+// RequestOption list is applied to the command header before sending.
+func (ns *networkService) submit(msg *kproto.Message, cmd *kproto.Command, value []byte, h *ResponseHandler, opts []RequestOption) error {
+	o := newRequestOptions(opts)
+	o.applyHeader(cmd.GetHeader())
+	if h != nil {
+		h.msgType = convertMessageTypeFromProto(cmd.GetHeader().GetMessageType())
+		h.log = ns.log()
+	}
+
+	if o.ctx != nil && o.ctx.Err() != nil {
+		if h != nil {
+			h.fail(Status{Code: ClientRequestCanceled, ErrorMsg: o.ctx.Err().Error()}, o.ctx.Err())
+		}
+		return o.ctx.Err()
+	}
+
+	if ns.isClosed() {
+		if h != nil {
+			h.fail(Status{Code: ClientShutdown, ErrorMsg: ErrConnectionClosed.Error()}, ErrConnectionClosed)
+		}
+		return ErrConnectionClosed
+	}
+
+	if err := validate(ns.deviceLog().Limits, msg, cmd, value); err != nil {
+		ns.log().error("Invalid request", Fields{FieldMessageType: cmd.GetHeader().GetMessageType().String(), FieldError: err.Error()})
+		if h != nil {
+			h.fail(Status{Code: ClientLimitExceeded, ErrorMsg: err.Error()}, err)
+		}
+		return err
+	}
+
+	// Wait for outstanding request slot, the slot is released when response received or request failed.
+	if h != nil {
+		release, err := ns.admission.Load().acquire(o.ctx, cmd.GetHeader().GetMessageType(), ns.closing)
+		if err != nil {
+			s := Status{Code: ClientLimitExceeded, ErrorMsg: err.Error()}
+			if err == ErrConnectionClosed {
+				s.Code = ClientShutdown
+			} else if o.ctx != nil && err == o.ctx.Err() {
+				s.Code = ClientRequestCanceled
+			}
+			h.fail(s, err)
+			return err
+		}
+		h.release = release
+	}
+
+	// Keep idempotent read request, so it can be resubmitted after reconnect.
+	if h != nil && ns.option.Reconnect != nil && ns.option.Reconnect.ResubmitReads &&
+		isIdempotent(cmd.GetHeader().GetMessageType()) {
+		h.request = &request{msg: msg, cmd: cmd, timeout: o.requestTimeout}
 	}
 
 	ns.txMu.Lock()
+	err := ns.sendLocked(msg, cmd, value, h, o.requestTimeout)
+	ns.txMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if h != nil && o.ctx != nil && o.ctx.Done() != nil {
+		go ns.watch(o.ctx, h)
+	}
+
+	return nil
+}
 
-	cmd.GetHeader().ConnectionID = &ns.connID
-	cmd.GetHeader().Sequence = &ns.seq
-	cmd.GetHeader().ClusterVersion = &ns.clusterVersion
+// sendLocked signs the message with current connection ID, sequence and cluster version, registers
+// the ResponseHandler and sends the message. Must be called with txMu held.
+// If timeout is not 0, it overrides the connection read and write timeout for this request.
+func (ns *networkService) sendLocked(msg *kproto.Message, cmd *kproto.Command, value []byte, h *ResponseHandler, timeout time.Duration) error {
+	seq := ns.seq
+	connID := ns.connID
+	clusterVersion := ns.clusterVersion
+	cmd.GetHeader().ConnectionID = &connID
+	cmd.GetHeader().Sequence = &seq
+	cmd.GetHeader().ClusterVersion = &clusterVersion
 
 	cmdBytes, err := proto.Marshal(cmd)
 	if err != nil {
-		klog.Error("Error marshl Kinetic Command")
+		ns.log().error("Error marshal Kinetic Command", Fields{FieldSequence: seq, FieldError: err.Error()})
 		s := Status{Code: ClientInternalError, ErrorMsg: "Error marshl Kinetic Command"}
-		ns.clientError(s, h)
+		if h != nil {
+			h.releaseSlot()
+			h.fail(s, err)
+		}
 		return err
 	}
 	msg.CommandBytes = cmdBytes[:]
@@ -238,162 +672,225 @@ func (ns *networkService) submit(msg *kproto.Message, cmd *kproto.Command, value
 		msg.GetHmacAuth().Hmac = computeHmac(msg.CommandBytes, ns.option.Hmac)
 	}
 
-	klog.Debug("Kinetic message send ", cmd.GetHeader().GetMessageType().String(), " Seq = ", ns.seq)
-
-	err = ns.send(msg, value)
-
-	if err != nil {
+	// Register ResponseHandler before sending, so response can't arrive before its handler.
+	ns.mapMu.Lock()
+	if ns.fatal {
+		err = ns.fatalError
+		ns.mapMu.Unlock()
+		err = errors.New("Can't submit, network service has fatal error: " + err.Error())
+		if h != nil {
+			h.releaseSlot()
+			h.fail(Status{Code: ClientIOError, ErrorMsg: err.Error()}, err)
+		}
 		return err
 	}
-
+	if ns.reconnecting {
+		ns.mapMu.Unlock()
+		s := Status{Code: ClientConnectionReset, ErrorMsg: "Can't submit, connection reset and reconnecting", Sequence: -1}
+		if h != nil {
+			h.releaseSlot()
+			h.fail(s, s)
+		}
+		return s.Err()
+	}
 	if h != nil {
+		h.seq = seq
+		h.deadline = time.Now().Add(ns.timeouts.read)
+		if timeout > 0 {
+			h.deadline = time.Now().Add(timeout)
+		}
+		ns.hmap[seq] = h
+		ns.updateReadDeadline()
+	}
+	ns.mapMu.Unlock()
+
+	if log := ns.log(); log.enabled(LogLevelDebug) {
+		log.debug("Kinetic message send", Fields{FieldMessageType: cmd.GetHeader().GetMessageType().String(), FieldSequence: seq})
+	}
+
+	ns.seq++
+
+	err = ns.send(msg, value, timeout)
+	if err != nil && h != nil {
 		ns.mapMu.Lock()
-		ns.hmap[ns.seq] = h
+		_, ok := ns.hmap[seq]
+		delete(ns.hmap, seq)
 		ns.mapMu.Unlock()
+		if ok {
+			h.releaseSlot()
+			h.fail(Status{Code: ClientIOError, ErrorMsg: err.Error()}, err)
+		}
 	}
 
-	ns.seq++
-	ns.txMu.Unlock()
+	return err
+}
 
-	return nil
+// watch abandons the request if ctx is done before response received. ResponseHandler is removed
+// from hmap, releases its outstanding request slot and fails with ctx.Err(), network connection is not affected.
+func (ns *networkService) watch(ctx context.Context, h *ResponseHandler) {
+	select {
+	case <-h.finished:
+		return
+	case <-ctx.Done():
+	}
+
+	// Request may be resubmitted with new sequence after reconnect.
+	ns.mapMu.Lock()
+	seq := h.seq
+	cur, ok := ns.hmap[seq]
+	ok = ok && cur == h
+	if ok {
+		delete(ns.hmap, seq)
+		ns.abandon(seq, time.Now())
+		ns.updateReadDeadline()
+	}
+	ns.mapMu.Unlock()
+
+	if ok {
+		h.logger().debug("Request abandoned", Fields{FieldError: ctx.Err().Error()})
+		h.releaseSlot()
+		h.fail(Status{Code: ClientRequestCanceled, ErrorMsg: ctx.Err().Error()}, ctx.Err())
+	}
 }
 
-func (ns *networkService) send(msg *kproto.Message, value []byte) error {
-	msgBytes, err := proto.Marshal(msg)
+func (ns *networkService) send(msg *kproto.Message, value []byte, timeout time.Duration) error {
+	packet, err := codec.NewWriter(ns.conn).Encode(&codec.Frame{Message: msg, Value: value})
 	if err != nil {
-		s := Status{Code: ClientInternalError, ErrorMsg: "Error marshl Kinetic Message"}
-		ns.clientError(s, nil)
+		ns.log().error("Error marshal Kinetic Message", Fields{FieldError: err.Error()})
 		return err
 	}
 
 	// Set timeout for send packet
-	ns.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
-
-	// Construct message header 9 bytes
-	header := make([]byte, 9)
-	header[0] = 'F' // Magic number
-	binary.BigEndian.PutUint32(header[1:5], uint32(len(msgBytes)))
-	binary.BigEndian.PutUint32(header[5:9], uint32(len(value)))
-
-	packet := append(header, msgBytes...)
-	if value != nil && len(value) > 0 {
-		packet = append(packet, value...)
+	if timeout <= 0 {
+		timeout = ns.timeouts.write
 	}
+	ns.conn.SetWriteDeadline(time.Now().Add(timeout))
 
 	_, err = ns.conn.Write(packet)
 	if err != nil {
-		klog.Error("Network I/O write error, " + err.Error())
-		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O write error, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
+		ns.log().error("Network I/O write error", Fields{FieldError: err.Error()})
+		// Wake up listen goroutine to handle the failure, connection is not usable anymore.
+		ns.conn.Close()
 		return err
 	}
 
 	return nil
 }
 
-func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, error) {
-	// Set timeout for receive packet
-	ns.conn.SetReadDeadline(time.Now().Add(requestTimeout))
-
-	header := make([]byte, 9)
+// receive reads one message from network connection, message exceeding limits is rejected before read.
+// Caller should set read deadline.
+func (ns *networkService) receive(conn net.Conn, limits codec.Limits) (*kproto.Message, *kproto.Command, []byte, error) {
+	rd := codec.NewReader(conn)
+	rd.Limits = limits
+	// Response HMAC is verified with the connection key, whatever identity it carries.
+	rd.Key = func(int64) ([]byte, bool) { return ns.option.Hmac, true }
 
-	_, err := io.ReadFull(ns.conn, header[0:])
+	h, err := rd.ReadHeader()
 	if err != nil {
-		klog.Error("Network I/O read error, " + err.Error())
-		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
-		return nil, nil, nil, err
-	}
-
-	magic := header[0]
-	if magic != 'F' {
-		klog.Error("Network I/O read error Header wrong magic")
-		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error Header wrong magic"}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = errors.New("Wrong magic number")
-		return nil, nil, nil, errors.New("Network I/O read error Header wrong magic")
+		// Error is returned as is only if no byte of header received.
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil, nil, nil, errReadTimeout
+		}
+		if err == codec.ErrWrongMagic {
+			ns.log().error("Network I/O read error Header wrong magic")
+			return nil, nil, nil, errors.New("Network I/O read error Header wrong magic")
+		}
+		return nil, nil, nil, errors.New("Network I/O read error, " + err.Error())
 	}
 
-	protoLen := int(binary.BigEndian.Uint32(header[1:5]))
-	valueLen := int(binary.BigEndian.Uint32(header[5:9]))
+	// Message started, the rest of message is read with read timeout, not deadline of requests.
+	ns.beginReceive(conn)
+	defer ns.endReceive(conn)
 
-	protoBuf := make([]byte, protoLen)
-	_, err = io.ReadFull(ns.conn, protoBuf)
-	if err != nil {
-		klog.Error("Network I/O read error receive Kinetic Header, " + err.Error())
-		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error receive Kinetic Header, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
-		return nil, nil, nil, err
+	// The whole message is read before HMAC verified, so the stream stays in frame even if message is rejected.
+	// On HMAC mismatch the message is returned with errResponseHMAC, so its request can be failed.
+	f, err := rd.ReadBody(h)
+	if err == codec.ErrHMACMismatch {
+		ns.log().error("Response HMAC mismatch")
+		return f.Message, f.Command, f.Value, errResponseHMAC
 	}
-
-	msg := &kproto.Message{}
-	err = proto.Unmarshal(protoBuf, msg)
 	if err != nil {
-		klog.Error("Network I/O read error receive Kinetic Header, " + err.Error())
-		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error reaceive Kinetic Message, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
-		return nil, nil, nil, err
+		ns.log().error("Network I/O read error", Fields{FieldError: err.Error()})
+		return nil, nil, nil, errors.New("Network I/O read error, " + err.Error())
 	}
 
-	if msg.GetAuthType() == kproto.Message_HMACAUTH && validateHmac(msg, ns.option.Hmac) == false {
-		klog.Error("Response HMAC mismatch")
-		s := Status{Code: ClientResponseHMACError, ErrorMsg: "Response HMAC mismatch"}
-		ns.clientError(s, nil)
-		return nil, nil, nil, err
-	}
+	return f.Message, f.Command, f.Value, nil
+}
 
-	cmd := &kproto.Command{}
-	err = proto.Unmarshal(msg.CommandBytes, cmd)
-	if err != nil {
-		klog.Error("Network I/O read error parsing Kinetic Command, " + err.Error())
-		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error parsing Kinetic Command, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
-		return nil, nil, nil, err
+// beginReceive sets read deadline for the rest of message partially received.
+func (ns *networkService) beginReceive(conn net.Conn) {
+	ns.mapMu.Lock()
+	if conn == ns.conn {
+		ns.receiving = true
 	}
+	ns.mapMu.Unlock()
+	conn.SetReadDeadline(time.Now().Add(ns.timeouts.read))
+}
 
-	if cmd.Header != nil && cmd.Header.ConnectionID != nil {
-		if ns.connID < 0 {
-			// This is handshake packet
-			ns.device = getLogFromProto(cmd)
+// endReceive restores read deadline after message received.
+func (ns *networkService) endReceive(conn net.Conn) {
+	ns.mapMu.Lock()
+	if conn == ns.conn {
+		ns.receiving = false
+		ns.updateReadDeadline()
+	}
+	ns.mapMu.Unlock()
+}
 
-			// Only update client cluster version during Handshake
-			if cmd.Header.ClusterVersion != nil {
-				ns.clusterVersion = cmd.GetHeader().GetClusterVersion()
-			}
+// shutdown stops accepting new requests, and waits for responses of outstanding requests until ctx is done.
+// Then network connection is closed, requests still outstanding fail with ClientShutdown.
+func (ns *networkService) shutdown(ctx context.Context) error {
+	ns.mapMu.Lock()
+	ns.markClosed()
+	var drained chan struct{}
+	if len(ns.hmap) > 0 {
+		if ns.drained == nil {
+			ns.drained = make(chan struct{})
 		}
-		ns.connID = cmd.GetHeader().GetConnectionID()
+		drained = ns.drained
 	}
+	ns.mapMu.Unlock()
 
-	if valueLen > 0 {
-		valueBuf := make([]byte, valueLen)
-		_, err = io.ReadFull(ns.conn, valueBuf)
-		if err != nil {
-			klog.Error("Network I/O read error parsing Kinetic Value, " + err.Error())
-			s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error parsing Kinetic Value, " + err.Error()}
-			ns.clientError(s, nil)
-			ns.fatal = true
-			ns.fatalError = err
-			return nil, nil, nil, err
+	var err error
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ns.done:
+		case <-ctx.Done():
+			err = ctx.Err()
 		}
-
-		return msg, cmd, valueBuf, nil
 	}
 
-	return msg, cmd, nil, nil
+	ns.close()
+	return err
 }
 
+// close closes network connection immediately, requests outstanding fail with ClientShutdown.
 func (ns *networkService) close() {
-	ns.conn.Close()
-	klog.Debugf("Connection to %s closed", ns.option.Host)
+	ns.mapMu.Lock()
+	ns.markClosed()
+	conn := ns.conn
+	ns.mapMu.Unlock()
+
+	conn.Close()
+	// Wait for listen goroutine to fail all outstanding ResponseHandler and exit.
+	<-ns.done
+	ns.log().debug("Connection closed")
+}
+
+// request keeps the message of idempotent read request, to resubmit after reconnect.
+type request struct {
+	msg     *kproto.Message
+	cmd     *kproto.Command
+	timeout time.Duration
+}
+
+// isIdempotent returns true for read requests which can be safely resubmitted.
+func isIdempotent(t kproto.Command_MessageType) bool {
+	switch t {
+	case kproto.Command_GET, kproto.Command_GETNEXT, kproto.Command_GETPREVIOUS,
+		kproto.Command_GETVERSION, kproto.Command_GETKEYRANGE, kproto.Command_GETLOG, kproto.Command_NOOP:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,78 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator
+
+import (
+	"context"
+	"sort"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+)
+
+// Simulated drive keeps some space for its own metadata, so capacity is never reported as empty.
+const reservedCapacity = 64 * 1024 * 1024
+
+// GetLog returns simulated logs, configuration and limits are filled by server.Server.
+func (b *backend) GetLog(ctx context.Context, types []kinetic.LogType) (*kinetic.Log, error) {
+	sim := b.sim
+	log := &kinetic.Log{}
+	for _, t := range types {
+		switch t {
+		case kinetic.LogTypeUtilizations:
+			log.Utilizations = []kinetic.UtilizationLog{
+				{Name: "HDA", Value: 0.01},
+				{Name: "EN0", Value: 0.02},
+				{Name: "EN1", Value: 0},
+				{Name: "CPU", Value: 0.05},
+			}
+		case kinetic.LogTypeTemperatures:
+			log.Temperatures = []kinetic.TemperatureLog{
+				{Name: "HDA", Current: 35, Minimum: 5, Maximum: 100, Target: 25},
+				{Name: "CPU", Current: 45, Minimum: 5, Maximum: 100, Target: 25},
+			}
+		case kinetic.LogTypeCapacities:
+			used := sim.store.usage() + reservedCapacity
+			log.Capacity = &kinetic.CapacityLog{
+				CapacityInBytes: sim.cfg.Capacity,
+				PortionFull:     float32(used) / float32(sim.cfg.Capacity),
+			}
+		case kinetic.LogTypeStatistics:
+			log.Statistics = sim.statistics()
+		case kinetic.LogTypeMessages:
+			log.Messages = []byte("kinetic simulator")
+		case kinetic.LogTypeDevice:
+			return nil, kinetic.Status{Code: kinetic.RemoteNotFound, ErrorMsg: "Device log not found"}
+		case kinetic.LogTypeConfiguration, kinetic.LogTypeLimits:
+		default:
+			return nil, kinetic.Status{Code: kinetic.RemoteInvalidRequest, ErrorMsg: "Unknown log type"}
+		}
+	}
+	return log, nil
+}
+
+func (s *Simulator) statistics() []kinetic.StatisticsLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]kinetic.StatisticsLog, 0, len(s.stats))
+	for _, st := range s.stats {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Type < stats[j].Type })
+	return stats
+}
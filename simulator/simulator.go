@@ -0,0 +1,275 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+/*
+Package simulator is an in-process kinetic drive simulator.
+
+It serves the kinetic protocol with package server on plain TCP and TLS listeners, so kinetic
+clients can be tested without a real drive:
+
+	sim, err := simulator.Start(simulator.Config{})
+	if err != nil {
+		panic(err)
+	}
+	defer sim.Close()
+
+	option := kinetic.ClientOptions{
+		Host: sim.Host(),
+		Port: sim.Port(),
+		User: simulator.DefaultIdentity,
+		Hmac: simulator.DefaultKey,
+	}
+
+The simulator keeps objects in memory, ordered by key, as server.Backend. Versions, key ranges,
+batches, ACLs, PIN operations, power levels and cluster versions are handled by server.Server.
+Notify, Disconnect and SetLatency inject failures.
+*/
+package simulator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/server"
+)
+
+// Default identity and HMAC key, same as the kinetic java simulator and factory drives.
+var (
+	DefaultIdentity int64 = 1
+	DefaultKey            = []byte("asdfasdf")
+)
+
+// AllPermissions are granted to DefaultIdentity by default.
+var AllPermissions = server.AllPermissions
+
+// Config defines how simulator is started.
+type Config struct {
+	Host           string             // Listen address, default 127.0.0.1
+	Port           int                // Plain TCP port, 0 picks a free port
+	TLSPort        int                // TLS port, 0 picks a free port
+	ACLs           []kinetic.ACL      // Default is DefaultIdentity with DefaultKey and AllPermissions
+	Limits         *kinetic.LimitsLog // Default is server.DefaultLimits
+	ClusterVersion int64
+	LockPin        []byte
+	ErasePin       []byte
+	Capacity       uint64        // Nominal capacity in bytes, default 4TB
+	Latency        time.Duration // Delay before each request is processed
+}
+
+// Simulator is a running simulated kinetic drive.
+type Simulator struct {
+	cfg   Config
+	srv   *server.Server
+	store store
+
+	mu      sync.Mutex
+	latency time.Duration
+	stats   map[kinetic.MessageType]*kinetic.StatisticsLog
+
+	ln    net.Listener
+	tlsLn net.Listener
+	cert  *x509.Certificate
+	wg    sync.WaitGroup
+}
+
+// Start creates a simulator and starts listening on both plain TCP and TLS ports.
+func Start(cfg Config) (*Simulator, error) {
+	if cfg.Host == "" {
+		cfg.Host = "127.0.0.1"
+	}
+	if cfg.Capacity == 0 {
+		cfg.Capacity = 4000000000000
+	}
+	if cfg.ACLs == nil {
+		cfg.ACLs = []kinetic.ACL{{
+			Identity: DefaultIdentity,
+			Key:      DefaultKey,
+			Scopes:   []kinetic.ACLScope{{Permissions: AllPermissions}},
+		}}
+	}
+
+	s := &Simulator{
+		cfg:     cfg,
+		latency: cfg.Latency,
+		stats:   make(map[kinetic.MessageType]*kinetic.StatisticsLog),
+	}
+
+	var err error
+	s.ln, err = net.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := selfSignedCertificate(cfg.Host)
+	if err != nil {
+		s.ln.Close()
+		return nil, err
+	}
+	s.cert = cert.Leaf
+	s.tlsLn, err = tls.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.TLSPort)),
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		s.ln.Close()
+		return nil, err
+	}
+
+	id := time.Now().Unix()
+	s.srv = server.New(&backend{sim: s}, server.Config{
+		ACLs:           cfg.ACLs,
+		ClusterVersion: cfg.ClusterVersion,
+		LockPin:        cfg.LockPin,
+		ErasePin:       cfg.ErasePin,
+		Limits:         cfg.Limits,
+		Configuration: &kinetic.ConfigurationLog{
+			Vendor:          "Seagate",
+			Model:           "Simulator",
+			SerialNumber:    []byte(fmt.Sprintf("SIM%08d", id%100000000)),
+			WorldWideName:   []byte(fmt.Sprintf("5000c500%08x", id&0xffffffff)),
+			Version:         "1.0.0",
+			ProtocolVersion: "3.1.0",
+			Interface: []kinetic.ConfigurationInterface{{
+				Name:     "lo",
+				MAC:      []byte{0, 0, 0, 0, 0, 0},
+				Ipv4Addr: []byte(net.ParseIP(cfg.Host).String()),
+			}},
+			Port:    int32(s.Port()),
+			TLSPort: int32(s.TLSPort()),
+		},
+		OnRequest: s.onRequest,
+	})
+
+	s.wg.Add(2)
+	go s.serve(s.ln)
+	go s.serve(s.tlsLn)
+
+	return s, nil
+}
+
+func (s *Simulator) serve(ln net.Listener) {
+	defer s.wg.Done()
+	s.srv.Serve(ln)
+}
+
+// onRequest counts statistics of request and applies latency.
+func (s *Simulator) onRequest(t kinetic.MessageType, valueSize int) {
+	s.mu.Lock()
+	st, ok := s.stats[t]
+	if !ok {
+		st = &kinetic.StatisticsLog{Type: t}
+		s.stats[t] = st
+	}
+	st.Count++
+	st.Bytes += uint64(valueSize)
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// Host returns the address simulator listens on.
+func (s *Simulator) Host() string {
+	return s.cfg.Host
+}
+
+// Port returns the plain TCP port.
+func (s *Simulator) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// TLSPort returns the TLS port.
+func (s *Simulator) TLSPort() int {
+	return s.tlsLn.Addr().(*net.TCPAddr).Port
+}
+
+// Addr returns host:port of the plain TCP listener.
+func (s *Simulator) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Certificate returns the self-signed certificate served on the TLS port.
+func (s *Simulator) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// CertPool returns a pool containing only the simulator certificate, to verify TLS connections.
+func (s *Simulator) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.cert)
+	return pool
+}
+
+// SetLatency changes the delay applied before each request is processed.
+func (s *Simulator) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// ClusterVersion returns the current drive cluster version.
+func (s *Simulator) ClusterVersion() int64 {
+	return s.srv.ClusterVersion()
+}
+
+// PowerLevel returns the current drive power level.
+func (s *Simulator) PowerLevel() kinetic.PowerLevel {
+	return s.srv.PowerLevel()
+}
+
+// Put stores record directly, bypassing protocol and ACL. Key, Value, Version, Tag and Algo are stored.
+func (s *Simulator) Put(r kinetic.Record) {
+	s.store.mu.Lock()
+	s.store.insert(stored(&r))
+	s.store.mu.Unlock()
+}
+
+// Get returns the stored record of key, or nil if not exist.
+func (s *Simulator) Get(key []byte) *kinetic.Record {
+	return s.store.get(key, false)
+}
+
+// Objects returns copy of all stored records, ordered by key.
+func (s *Simulator) Objects() []kinetic.Record {
+	return s.store.snapshot()
+}
+
+// Notify sends an unsolicited status to all connected clients. For terminal status
+// RemoteConnectionTerminated, RemoteHibernate and RemoteShutdown, connections are closed afterwards.
+func (s *Simulator) Notify(code kinetic.StatusCode, msg string) {
+	s.srv.Notify(code, msg)
+}
+
+// Disconnect closes all client connections, without notification.
+func (s *Simulator) Disconnect() {
+	s.srv.Disconnect()
+}
+
+// Close stops listening and closes all client connections.
+func (s *Simulator) Close() error {
+	err := s.srv.Close()
+	s.ln.Close()
+	s.tlsLn.Close()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,186 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator_test
+
+import (
+	"bytes"
+	"testing"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+func startSimulator(t *testing.T, cfg simulator.Config) *simulator.Simulator {
+	sim, err := simulator.Start(cfg)
+	if err != nil {
+		t.Fatal("Simulator start Failure", err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	return sim
+}
+
+func connect(t *testing.T, sim *simulator.Simulator, identity int64, key []byte) *kinetic.BlockConnection {
+	conn, err := kinetic.NewBlockConnection(kinetic.ClientOptions{
+		Host: sim.Host(),
+		Port: sim.Port(),
+		User: identity,
+		Hmac: key,
+	})
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSimulatorVersions(t *testing.T) {
+	sim := startSimulator(t, simulator.Config{})
+	conn := connect(t, sim, simulator.DefaultIdentity, simulator.DefaultKey)
+
+	entry := kinetic.Record{Key: []byte("key"), Value: []byte("value"), NewVersion: []byte("v1"),
+		Sync: kinetic.SyncWriteThrough, Algo: kinetic.AlgorithmSHA1}
+	if status, err := conn.Put(&entry); err != nil || status.Code != kinetic.OK {
+		t.Fatal("Put Failure", err, status.String())
+	}
+	if o := sim.Get([]byte("key")); o == nil || !bytes.Equal(o.Version, []byte("v1")) {
+		t.Fatal("Object not stored with version", o)
+	}
+
+	// Version mismatch rejected
+	entry.Version, entry.NewVersion = []byte("v0"), []byte("v2")
+	if status, _ := conn.Put(&entry); status.Code != kinetic.RemoteVersionMismatch {
+		t.Fatal("Put with wrong version expect RemoteVersionMismatch", status.String())
+	}
+	entry.Version = []byte("v1")
+	if status, err := conn.Put(&entry); err != nil || status.Code != kinetic.OK {
+		t.Fatal("Put with current version Failure", err, status.String())
+	}
+	if version, status, err := conn.GetVersion([]byte("key")); err != nil || !bytes.Equal(version, []byte("v2")) {
+		t.Fatal("GetVersion Failure", err, status.String(), string(version))
+	}
+}
+
+func TestSimulatorACL(t *testing.T) {
+	sim := startSimulator(t, simulator.Config{
+		ACLs: []kinetic.ACL{
+			{Identity: simulator.DefaultIdentity, Key: simulator.DefaultKey,
+				Scopes: []kinetic.ACLScope{{Permissions: simulator.AllPermissions}}},
+			{Identity: 2, Key: []byte("reader"),
+				Scopes: []kinetic.ACLScope{{Value: []byte("public"), Permissions: []kinetic.ACLPermission{kinetic.ACLPermissionRead}}}},
+		},
+	})
+	sim.Put(kinetic.Record{Key: []byte("public/a"), Value: []byte("a")})
+	sim.Put(kinetic.Record{Key: []byte("private/a"), Value: []byte("a")})
+
+	conn := connect(t, sim, 2, []byte("reader"))
+	if _, status, err := conn.Get([]byte("public/a")); err != nil || status.Code != kinetic.OK {
+		t.Fatal("Get in scope Failure", err, status.String())
+	}
+	if _, status, _ := conn.Get([]byte("private/a")); status.Code != kinetic.RemoteNotAuthorized {
+		t.Fatal("Get out of scope expect RemoteNotAuthorized", status.String())
+	}
+	entry := kinetic.Record{Key: []byte("public/b"), Value: []byte("b"), Force: true,
+		Sync: kinetic.SyncWriteThrough, Algo: kinetic.AlgorithmSHA1}
+	if status, _ := conn.Put(&entry); status.Code != kinetic.RemoteNotAuthorized {
+		t.Fatal("Put without WRITE permission expect RemoteNotAuthorized", status.String())
+	}
+}
+
+func TestSimulatorClusterVersion(t *testing.T) {
+	sim := startSimulator(t, simulator.Config{ClusterVersion: 3})
+	conn := connect(t, sim, simulator.DefaultIdentity, simulator.DefaultKey)
+
+	// Cluster version from handshake is used by client
+	if status, err := conn.NoOp(); err != nil || status.Code != kinetic.OK {
+		t.Fatal("NoOp Failure", err, status.String())
+	}
+	conn.SetClientClusterVersion(1)
+	if status, _ := conn.NoOp(); status.Code != kinetic.RemoteClusterVersionMismatch {
+		t.Fatal("NoOp with wrong cluster version expect RemoteClusterVersionMismatch", status.String())
+	}
+	if sim.ClusterVersion() != 3 {
+		t.Fatal("Simulator cluster version changed", sim.ClusterVersion())
+	}
+}
+
+func TestSimulatorPinOperations(t *testing.T) {
+	sim := startSimulator(t, simulator.Config{})
+	sim.Put(kinetic.Record{Key: []byte("key"), Value: []byte("value")})
+
+	// PIN operations require TLS connection
+	conn, err := kinetic.NewBlockConnection(kinetic.ClientOptions{
+		Host:   sim.Host(),
+		Port:   sim.TLSPort(),
+		User:   simulator.DefaultIdentity,
+		Hmac:   simulator.DefaultKey,
+		UseSSL: true,
+		TLS:    &kinetic.TLSOptions{RootCAs: sim.CertPool()},
+	})
+	if err != nil {
+		t.Fatal("TLS connection Failure", err)
+	}
+	defer conn.Close()
+
+	if status, err := conn.SetLockPin(nil, []byte("lock")); err != nil || status.Code != kinetic.OK {
+		t.Fatal("SetLockPin Failure", err, status.String())
+	}
+	if status, _ := conn.LockDevice([]byte("wrong")); status.Code != kinetic.RemoteNotAuthorized {
+		t.Fatal("LockDevice with wrong PIN expect RemoteNotAuthorized", status.String())
+	}
+	if status, err := conn.LockDevice([]byte("lock")); err != nil || status.Code != kinetic.OK {
+		t.Fatal("LockDevice Failure", err, status.String())
+	}
+	if _, status, _ := conn.Get([]byte("key")); status.Code != kinetic.RemoteDeviceLocked {
+		t.Fatal("Get on locked device expect RemoteDeviceLocked", status.String())
+	}
+	if status, err := conn.UnlockDevice([]byte("lock")); err != nil || status.Code != kinetic.OK {
+		t.Fatal("UnlockDevice Failure", err, status.String())
+	}
+
+	if status, err := conn.InstantErase(nil); err != nil || status.Code != kinetic.OK {
+		t.Fatal("InstantErase Failure", err, status.String())
+	}
+	if len(sim.Objects()) != 0 {
+		t.Fatal("Objects not erased", sim.Objects())
+	}
+}
+
+func TestSimulatorPowerLevel(t *testing.T) {
+	sim := startSimulator(t, simulator.Config{})
+	conn := connect(t, sim, simulator.DefaultIdentity, simulator.DefaultKey)
+
+	if status, err := conn.SetPowerLevel(kinetic.PowerLevelHibernate); err != nil || status.Code != kinetic.OK {
+		t.Fatal("SetPowerLevel Failure", err, status.String())
+	}
+	if sim.PowerLevel() != kinetic.PowerLevelHibernate {
+		t.Fatal("Simulator power level not changed", sim.PowerLevel())
+	}
+	if _, status, _ := conn.Get([]byte("key")); status.Code != kinetic.RemoteHibernate {
+		t.Fatal("Get on hibernating device expect RemoteHibernate", status.String())
+	}
+	log, status, err := conn.GetLog([]kinetic.LogType{kinetic.LogTypeConfiguration})
+	if err != nil || status.Code != kinetic.OK {
+		t.Fatal("GetLog on hibernating device Failure", err, status.String())
+	}
+	if log.Configuration.CurrentPowerLevel != kinetic.PowerLevelHibernate {
+		t.Fatal("GetLog expect current power level", log.Configuration)
+	}
+	if status, err := conn.SetPowerLevel(kinetic.PowerLevelOperational); err != nil || status.Code != kinetic.OK {
+		t.Fatal("SetPowerLevel Failure", err, status.String())
+	}
+}
@@ -0,0 +1,286 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+
+	kinetic "github.com/Kinetic/kinetic-go"
+	"github.com/Kinetic/kinetic-go/server"
+)
+
+// stored returns copy of the parts of r kept by store.
+func stored(r *kinetic.Record) *kinetic.Record {
+	return &kinetic.Record{
+		Key:     append([]byte(nil), r.Key...),
+		Value:   append([]byte(nil), r.Value...),
+		Version: append([]byte(nil), r.Version...),
+		Tag:     append([]byte(nil), r.Tag...),
+		Algo:    r.Algo,
+	}
+}
+
+// store keeps records ordered by key, as kinetic drive does.
+type store struct {
+	mu      sync.RWMutex
+	records []*kinetic.Record // sorted by Key
+	used    uint64            // bytes used by keys and values
+}
+
+// find returns index of the first record with key >= key, and whether it's an exact match.
+func (st *store) find(key []byte) (int, bool) {
+	i := sort.Search(len(st.records), func(i int) bool {
+		return bytes.Compare(st.records[i].Key, key) >= 0
+	})
+	return i, i < len(st.records) && bytes.Equal(st.records[i].Key, key)
+}
+
+// at returns copy of record at index i, nil if out of range. Value is omitted if metaOnly.
+func (st *store) at(i int, metaOnly bool) *kinetic.Record {
+	if i < 0 || i >= len(st.records) {
+		return nil
+	}
+	r := stored(st.records[i])
+	if metaOnly {
+		r.Value = nil
+	}
+	return r
+}
+
+func (st *store) get(key []byte, metaOnly bool) *kinetic.Record {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	if i, ok := st.find(key); ok {
+		return st.at(i, metaOnly)
+	}
+	return nil
+}
+
+func (st *store) next(key []byte, metaOnly bool) *kinetic.Record {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i, ok := st.find(key)
+	if ok {
+		i++
+	}
+	return st.at(i, metaOnly)
+}
+
+func (st *store) previous(key []byte, metaOnly bool) *kinetic.Record {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i, _ := st.find(key)
+	return st.at(i-1, metaOnly)
+}
+
+// keyRange returns at most r.Max keys within range, in reverse order if requested.
+func (st *store) keyRange(r *kinetic.KeyRange) [][]byte {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	inRange := func(key []byte) bool {
+		c := bytes.Compare(key, r.StartKey)
+		if c < 0 || (c == 0 && !r.StartKeyInclusive) {
+			return false
+		}
+		if len(r.EndKey) > 0 {
+			c = bytes.Compare(key, r.EndKey)
+			if c > 0 || (c == 0 && !r.EndKeyInclusive) {
+				return false
+			}
+		}
+		return true
+	}
+
+	max := int(r.Max)
+	keys := make([][]byte, 0)
+	if r.Reverse {
+		for i := len(st.records) - 1; i >= 0 && len(keys) < max; i-- {
+			if inRange(st.records[i].Key) {
+				keys = append(keys, append([]byte(nil), st.records[i].Key...))
+			}
+		}
+	} else {
+		i, _ := st.find(r.StartKey)
+		for ; i < len(st.records) && len(keys) < max; i++ {
+			if inRange(st.records[i].Key) {
+				keys = append(keys, append([]byte(nil), st.records[i].Key...))
+			}
+		}
+	}
+	return keys
+}
+
+// check verifies the version precondition of op against current record cur, nil if not exist.
+func check(op *server.BatchOp, cur *kinetic.Record) error {
+	r := op.Record
+	if r.Force {
+		return nil
+	}
+	if cur == nil {
+		if op.Type == kinetic.MessageDelete {
+			return kinetic.RemoteNotFound
+		}
+		if len(r.Version) > 0 {
+			return &kinetic.VersionConflictError{Key: r.Key, ExpectedVersion: r.Version,
+				Status: kinetic.Status{Code: kinetic.RemoteVersionMismatch, ErrorMsg: "Version mismatch"}}
+		}
+		return nil
+	}
+	if !bytes.Equal(cur.Version, r.Version) {
+		return &kinetic.VersionConflictError{Key: r.Key, ExpectedVersion: r.Version, CurrentVersion: cur.Version,
+			Status: kinetic.Status{Code: kinetic.RemoteVersionMismatch, ErrorMsg: "Version mismatch"}}
+	}
+	return nil
+}
+
+// apply performs all operations atomically. On failure nothing is changed, and *server.BatchError
+// identifies the failed operation.
+func (st *store) apply(ops []server.BatchOp) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Check all preconditions against the state as it evolves through the operations.
+	pending := make(map[string]*kinetic.Record)
+	for k := range ops {
+		op := &ops[k]
+		cur, seen := pending[string(op.Record.Key)]
+		if !seen {
+			if i, ok := st.find(op.Record.Key); ok {
+				cur = st.records[i]
+			}
+		}
+		if err := check(op, cur); err != nil {
+			return &server.BatchError{Sequence: op.Sequence, Err: err}
+		}
+		if op.Type == kinetic.MessageDelete {
+			pending[string(op.Record.Key)] = nil
+		} else {
+			r := stored(op.Record)
+			r.Version = op.Record.NewVersion
+			pending[string(op.Record.Key)] = r
+		}
+	}
+
+	for _, op := range ops {
+		if r := pending[string(op.Record.Key)]; r != nil {
+			st.insert(r)
+		} else {
+			st.remove(op.Record.Key)
+		}
+	}
+	return nil
+}
+
+func (st *store) insert(r *kinetic.Record) {
+	i, ok := st.find(r.Key)
+	if ok {
+		st.used -= uint64(len(st.records[i].Key) + len(st.records[i].Value))
+		st.records[i] = r
+	} else {
+		st.records = append(st.records, nil)
+		copy(st.records[i+1:], st.records[i:])
+		st.records[i] = r
+	}
+	st.used += uint64(len(r.Key) + len(r.Value))
+}
+
+func (st *store) remove(key []byte) {
+	if i, ok := st.find(key); ok {
+		st.used -= uint64(len(st.records[i].Key) + len(st.records[i].Value))
+		st.records = append(st.records[:i], st.records[i+1:]...)
+	}
+}
+
+func (st *store) erase() {
+	st.mu.Lock()
+	st.records = nil
+	st.used = 0
+	st.mu.Unlock()
+}
+
+func (st *store) usage() uint64 {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.used
+}
+
+func (st *store) snapshot() []kinetic.Record {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	records := make([]kinetic.Record, len(st.records))
+	for k, r := range st.records {
+		records[k] = *stored(r)
+	}
+	return records
+}
+
+// backend is the server.Backend of Simulator.
+type backend struct {
+	sim *Simulator
+}
+
+func (b *backend) Get(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error) {
+	return b.sim.store.get(key, metaOnly), nil
+}
+
+func (b *backend) GetNext(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error) {
+	return b.sim.store.next(key, metaOnly), nil
+}
+
+func (b *backend) GetPrevious(ctx context.Context, key []byte, metaOnly bool) (*kinetic.Record, error) {
+	return b.sim.store.previous(key, metaOnly), nil
+}
+
+func (b *backend) GetKeyRange(ctx context.Context, r *kinetic.KeyRange) ([][]byte, error) {
+	return b.sim.store.keyRange(r), nil
+}
+
+func (b *backend) Put(ctx context.Context, r *kinetic.Record) error {
+	return b.write(server.BatchOp{Type: kinetic.MessagePut, Record: r})
+}
+
+func (b *backend) Delete(ctx context.Context, r *kinetic.Record) error {
+	return b.write(server.BatchOp{Type: kinetic.MessageDelete, Record: r})
+}
+
+// write applies single operation, failure is returned as is.
+func (b *backend) write(op server.BatchOp) error {
+	err := b.sim.store.apply([]server.BatchOp{op})
+	if batchErr, ok := err.(*server.BatchError); ok {
+		return batchErr.Err
+	}
+	return err
+}
+
+func (b *backend) Batch(ctx context.Context, ops []server.BatchOp) error {
+	return b.sim.store.apply(ops)
+}
+
+func (b *backend) Setup(ctx context.Context, s *server.Setup) error {
+	// Firmware download is accepted, but not applied.
+	return nil
+}
+
+func (b *backend) Erase(ctx context.Context, secure bool) error {
+	b.sim.store.erase()
+	return nil
+}
@@ -18,6 +18,7 @@
 package kinetic
 
 import (
+	"fmt"
 	"strconv"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
@@ -61,6 +62,10 @@ const (
 	RemoteExecuteComplete              StatusCode = iota
 	RemoteHibernate                    StatusCode = iota
 	RemoteShutdown                     StatusCode = iota
+	ClientRequestCanceled              StatusCode = iota
+	ClientConnectionReset              StatusCode = iota
+	ClientLimitExceeded                StatusCode = iota
+	ClientRequestTimeout               StatusCode = iota
 )
 
 var statusName = map[StatusCode]string{
@@ -96,6 +101,10 @@ var statusName = map[StatusCode]string{
 	RemoteExecuteComplete:              "REMOTE_EXECUTE_COMPLETE",
 	RemoteHibernate:                    "REMOTE_HIBERNATE",
 	RemoteShutdown:                     "REMOTE_SHUTDOWN",
+	ClientRequestCanceled:              "CLIENT_REQUEST_CANCELED",
+	ClientConnectionReset:              "CLIENT_CONNECTION_RESET",
+	ClientLimitExceeded:                "CLIENT_LIMIT_EXCEEDED",
+	ClientRequestTimeout:               "CLIENT_REQUEST_TIMEOUT",
 }
 
 // String returns string value of StatusCode.
@@ -108,12 +117,22 @@ func (c StatusCode) String() string {
 	return "Unknown Status"
 }
 
+// Error returns string value of StatusCode, so StatusCode can be used as target of errors.Is.
+func (c StatusCode) Error() string {
+	return c.String()
+}
+
 // Status for each kinetic message.
-// Code is the status code and ErrorMsg is the detail message
+// Code is the status code and ErrorMsg is the detail message.
+// For failed request, DetailedMessage, MessageType and Sequence give details of the failed command.
+// Status is comparable, so it can be compared with == also as error.
 type Status struct {
 	Code                   StatusCode
 	ErrorMsg               string
 	ExpectedClusterVersion int64
+	DetailedMessage        string      // Detailed message from kinetic device, if any
+	MessageType            MessageType // Message type of the request
+	Sequence               int64       // Sequence of the request, -1 if request not sent to device
 }
 
 // Error returns the detail status message if Status.Code != OK
@@ -121,6 +140,25 @@ func (s Status) Error() string {
 	return s.ErrorMsg
 }
 
+// Is reports whether target is StatusCode or Status with the same status code, for errors.Is.
+func (s Status) Is(target error) bool {
+	switch t := target.(type) {
+	case StatusCode:
+		return s.Code == t
+	case Status:
+		return s.Code == t.Code
+	}
+	return false
+}
+
+// Err returns nil if Status.Code is OK, otherwise returns *Error wraps the Status.
+func (s Status) Err() error {
+	if s.Code == OK {
+		return nil
+	}
+	return &Error{Status: s}
+}
+
 func (s Status) String() string {
 	ret := "Unknown Status"
 	str, ok := statusName[s.Code]
@@ -133,6 +171,26 @@ func (s Status) String() string {
 	return ret
 }
 
+// VersionConflictError is returned by Put and Delete when Record.Version doesn't match
+// the version of the object on kinetic device, and Record.Force is not set.
+type VersionConflictError struct {
+	Key             []byte // Key of the object
+	ExpectedVersion []byte // Version expected by the request, from Record.Version
+	CurrentVersion  []byte // Version held by kinetic device, nil if object doesn't exist or device didn't report it
+	Status          Status // Status returned by kinetic device, Code is RemoteVersionMismatch
+}
+
+// Error returns the detail message of version conflict.
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict for key %s: expected version [%x], device version [%x]",
+		e.Key, e.ExpectedVersion, e.CurrentVersion)
+}
+
+// Unwrap returns the Status of version conflict.
+func (e *VersionConflictError) Unwrap() error {
+	return e.Status
+}
+
 func convertStatusCodeToProto(s StatusCode) kproto.Command_Status_StatusCode {
 	ret := kproto.Command_Status_INVALID_STATUS_CODE
 	switch s {
@@ -247,6 +305,17 @@ func getStatusFromProto(cmd *kproto.Command) Status {
 	code := convertStatusCodeFromProto(cmd.GetStatus().GetCode())
 	msg := cmd.GetStatus().GetStatusMessage()
 	version := cmd.GetHeader().GetClusterVersion()
+	var seq int64 = -1
+	if cmd.GetHeader() != nil && cmd.GetHeader().AckSequence != nil {
+		seq = cmd.GetHeader().GetAckSequence()
+	}
 
-	return Status{code, msg, version}
+	return Status{
+		Code:                   code,
+		ErrorMsg:               msg,
+		ExpectedClusterVersion: version,
+		DetailedMessage:        string(cmd.GetStatus().GetDetailedMessage()),
+		MessageType:            convertMessageTypeFromProto(cmd.GetHeader().GetMessageType()),
+		Sequence:               seq,
+	}
 }
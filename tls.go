@@ -0,0 +1,115 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// TLSOptions specify how SSL connection to kinetic device is verified.
+// Device certificate chain is verified against RootCAs and ServerName, unless InsecureSkipVerify is true.
+// If PinnedFingerprints is not empty, device certificate must match one of the fingerprints,
+// even if InsecureSkipVerify is true. This allows to trust self-signed device certificates.
+type TLSOptions struct {
+	RootCAs            *x509.CertPool    // CA pool to verify device certificate, nil to use system CA pool
+	Certificates       []tls.Certificate // Client certificates presented to kinetic device
+	ServerName         string            // Name to verify device certificate, default is ClientOptions.Host
+	PinnedFingerprints [][]byte          // SHA-256 fingerprints of accepted device certificates
+	InsecureSkipVerify bool              // Skip verification of device certificate chain and name
+}
+
+// TLSVerificationError is returned when establishing SSL connection if kinetic device certificate
+// can't be verified, eg. unknown authority, name mismatch or fingerprint not pinned.
+type TLSVerificationError struct {
+	Host string // Kinetic device address
+	Err  error  // Verification failure details
+}
+
+// Error returns the detail message of verification failure.
+func (e *TLSVerificationError) Error() string {
+	return fmt.Sprintf("TLS verification of device %s failed: %s", e.Host, e.Err.Error())
+}
+
+// Unwrap returns the verification failure details.
+func (e *TLSVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// errFingerprintMismatch is returned when device certificate doesn't match any pinned fingerprint.
+var errFingerprintMismatch = errors.New("device certificate doesn't match pinned fingerprints")
+
+// CertificateFingerprint returns the SHA-256 fingerprint of certificate, as used by TLSOptions.PinnedFingerprints.
+func CertificateFingerprint(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.Raw)
+	return sum[:]
+}
+
+// newTLSConfig builds TLS configuration from ClientOptions.
+// Without TLSOptions, device certificate is not verified.
+func newTLSConfig(op ClientOptions) *tls.Config {
+	if op.TLS == nil {
+		newContextLogger(op.Logger, Fields{FieldHost: op.Host}).warn("No TLSOptions for SSL connection, kinetic device certificate won't be verified")
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+
+	config := &tls.Config{
+		RootCAs:            op.TLS.RootCAs,
+		Certificates:       op.TLS.Certificates,
+		ServerName:         op.TLS.ServerName,
+		InsecureSkipVerify: op.TLS.InsecureSkipVerify,
+	}
+	if config.ServerName == "" {
+		config.ServerName = op.Host
+	}
+
+	if len(op.TLS.PinnedFingerprints) > 0 {
+		pins := op.TLS.PinnedFingerprints
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errFingerprintMismatch
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+			return errFingerprintMismatch
+		}
+	}
+
+	return config
+}
+
+// isTLSVerificationError returns true if err from SSL handshake is certificate verification failure.
+func isTLSVerificationError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	return errors.Is(err, errFingerprintMismatch) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &verification)
+}
@@ -0,0 +1,104 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// UnsolicitedStatus is the status message sent by kinetic device without request, eg. when
+// kinetic device terminates the connection, hibernates or shuts down.
+type UnsolicitedStatus struct {
+	Status   Status // Status code and message from kinetic device
+	Terminal bool   // Connection terminated by kinetic device, connection is closed unless ClientOptions.Reconnect is set
+}
+
+// UnsolicitedStatusFunc is called for each unsolicited status message received on the connection.
+// It's called from network service goroutine, it shouldn't block or wait for response of other requests.
+type UnsolicitedStatusFunc func(UnsolicitedStatus)
+
+type subscriber struct {
+	id int
+	f  UnsolicitedStatusFunc
+}
+
+// isTerminal returns true if kinetic device terminates the connection after sending the status.
+func isTerminal(code StatusCode) bool {
+	switch code {
+	case RemoteConnectionTerminated, RemoteHibernate, RemoteShutdown:
+		return true
+	}
+	return false
+}
+
+// isUnsolicited returns true if message is an unsolicited status not responding to any request.
+func isUnsolicited(msg *kproto.Message, cmd *kproto.Command) bool {
+	return msg.GetAuthType() == kproto.Message_UNSOLICITEDSTATUS &&
+		(cmd.GetHeader() == nil || cmd.GetHeader().AckSequence == nil)
+}
+
+// subscribe adds f to receive unsolicited status, returns the function to remove it.
+func (ns *networkService) subscribe(f UnsolicitedStatusFunc) func() {
+	ns.mapMu.Lock()
+	id := ns.subscriberID
+	ns.subscriberID++
+	ns.subscribers = append(ns.subscribers, subscriber{id: id, f: f})
+	ns.mapMu.Unlock()
+
+	return func() {
+		ns.mapMu.Lock()
+		defer ns.mapMu.Unlock()
+		for k, sub := range ns.subscribers {
+			if sub.id == id {
+				ns.subscribers = append(ns.subscribers[:k:k], ns.subscribers[k+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// unsolicited delivers unsolicited status to subscribers. For terminal status, connection is reconnected
+// if ClientOptions.Reconnect is set, otherwise connection is closed and all outstanding ResponseHandler fail
+// with the status. Returns true if connection is closed.
+func (ns *networkService) unsolicited(cmd *kproto.Command) bool {
+	s := getStatusFromProto(cmd)
+	event := UnsolicitedStatus{Status: s, Terminal: isTerminal(s.Code)}
+	reconnect := event.Terminal && ns.option.Reconnect != nil
+	ns.log().warn("Kinetic UNSOLICITEDSTATUS received", Fields{FieldStatus: s.String()})
+
+	ns.mapMu.Lock()
+	subscribers := ns.subscribers
+	if event.Terminal && !reconnect {
+		ns.markClosed()
+	}
+	ns.mapMu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.f(event)
+	}
+
+	if !event.Terminal {
+		return false
+	}
+	if reconnect && !ns.isClosed() && ns.reconnect(s) {
+		return false
+	}
+	ns.conn.Close()
+	ns.clientError(s, s)
+	return true
+}
@@ -32,20 +32,20 @@ func UpdateFirmware(conn *BlockConnection, file string) error {
 	_, err := os.Stat(file)
 	if err != nil {
 		if os.IsNotExist(err) {
-			klog.Errorf("Update firmware fail, file %s not exist", file)
+			conn.log().error("Update firmware fail, file not exist", Fields{"file": file})
 		}
 		return err
 	}
 
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		klog.Errorf("Update firmware fail, file %s can't read", file)
+		conn.log().error("Update firmware fail, file can't read", Fields{"file": file, FieldError: err.Error()})
 		return err
 	}
 
 	status, err := conn.UpdateFirmware(data)
 	if err != nil || status.Code != OK {
-		klog.Errorf("Update firmware fail : %s\n", status.Error())
+		conn.log().error("Update firmware fail", Fields{FieldStatus: status.String()})
 	}
 
 	return err
@@ -60,7 +60,7 @@ func UploadFile(conn *BlockConnection, file string, keys [][]byte, chunkSize int
 	info, err := os.Stat(file)
 	if err != nil {
 		if os.IsNotExist(err) {
-			klog.Errorf("Upload fail, file %s not exist", file)
+			conn.log().error("Upload fail, file not exist", Fields{"file": file})
 		}
 		return nil, err
 	}
@@ -117,7 +117,7 @@ func UploadFile(conn *BlockConnection, file string, keys [][]byte, chunkSize int
 		sts, err := conn.Put(&entry)
 		status = append(status, sts)
 		if err != nil || sts.Code != OK {
-			klog.Errorf("Upload fail for chunk[%02d], key[%s] : %s\n", cnt, keys[cnt], sts.Error())
+			conn.log().error("Upload fail", Fields{"chunk": cnt, "key": string(keys[cnt]), FieldStatus: sts.String()})
 			// TODO: Should delete already PUT objects???
 			return status, err
 		}